@@ -2,16 +2,18 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net"
 	"os"
-	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Dbaker1298/pScan/scan"
+	"github.com/Dbaker1298/pScan/scan/scantest"
 )
 
 // Since this app saves the hosts list to a file, we need to create a temporary file.
@@ -45,6 +47,17 @@ func setup(t *testing.T, hosts []string, initList bool) (string, func()) {
 	}
 }
 
+// stopClock makes clock return a fixed time, so the elapsed time in the
+// scan summary is always 0s.
+func stopClock(t *testing.T) {
+	t.Helper()
+
+	now := time.Date(2023, 11, 12, 10, 0, 0, 0, time.UTC)
+	clock = func() time.Time { return now }
+
+	t.Cleanup(func() { clock = time.Now })
+}
+
 func TestHostActions(t *testing.T) {
 	// Define hosts for actions test
 	hosts := []string{"host1", "host2", "host3"}
@@ -110,48 +123,31 @@ func TestScanAction(t *testing.T) {
 	tf, cleanup := setup(t, hosts, true)
 	defer cleanup()
 
-	ports := []int{}
+	stopClock(t)
 
-	// Init port, 1 open, 1 closed
-	for i := 0; i < 2; i++ {
-		ln, err := net.Listen("tcp", net.JoinHostPort(hosts[0], "0"))
-		if err != nil {
-			t.Fatalf("Failed to listen on port: %v\n", err)
-		}
-
-		defer ln.Close()
+	// Init ports, 1 open, 1 closed. Ports that are not declared are closed.
+	ports := []int{22, 23}
 
-		_, portStr, err := net.SplitHostPort(ln.Addr().String())
-		if err != nil {
-			t.Fatalf("Failed to split host and port: %v\n", err)
-		}
-
-		portInt, err := strconv.Atoi(portStr)
-		if err != nil {
-			t.Fatalf("Failed to convert port string to int: %v\n", err)
-		}
-
-		ports = append(ports, portInt)
-
-		if i == 1 {
-			ln.Close()
-		}
-	}
+	n := scantest.NewNetwork()
+	n.AddHost("localhost", "127.0.0.1").TCP(ports[0], scan.StateOpen)
 
 	// Define expected output for scan action
 	expectedout := fmt.Sprintln("localhost:")
-	expectedout += fmt.Sprintf("\t%d: open\n", ports[0])
-	expectedout += fmt.Sprintf("\t%d: closed\n", ports[1])
+	expectedout += fmt.Sprintf("\t127.0.0.1:%d: open (syn-ack)\n", ports[0])
+	expectedout += fmt.Sprintf("\t127.0.0.1:%d: closed (conn-refused)\n", ports[1])
 	expectedout += fmt.Sprintln()
 	expectedout += fmt.Sprintln("unknownhostoutthere: Host not found")
 	expectedout += fmt.Sprintln()
+	expectedout += fmt.Sprintln("2 hosts scanned (1 up, 1 not found): 1 open, 1 closed, 0 filtered, 0 unreachable ports in 0s")
 
 	// Define var to capture Action output
 
 	var out bytes.Buffer
 
 	// Execute Action and capture output
-	if err := scanAction(&out, tf, ports); err != nil {
+	cfg := scan.Config{Dialer: n, Resolver: n}
+
+	if err := scanAction(context.Background(), &out, statusOptions{}, tf, ports, cfg, outputOptions{}); err != nil {
 		t.Fatalf("Expected no error, got: %q\n", err)
 	}
 
@@ -172,6 +168,8 @@ func TestIntegration(t *testing.T) {
 	tf, cleanup := setup(t, hosts, false)
 	defer cleanup()
 
+	stopClock(t)
+
 	delHost := "host2"
 
 	hostsEnd := []string{"host1", "host3"}
@@ -193,6 +191,7 @@ func TestIntegration(t *testing.T) {
 		expectedOut += fmt.Sprintf("%s: Host not found\n", v)
 		expectedOut += fmt.Sprintln()
 	}
+	expectedOut += fmt.Sprintln("2 hosts scanned (0 up, 2 not found): 0 open, 0 closed, 0 filtered, 0 unreachable ports in 0s")
 
 	// Execute all actions in the defined order; add -> list -> delete -> list -> scan
 	// Add hosts to the list
@@ -215,8 +214,10 @@ func TestIntegration(t *testing.T) {
 		t.Fatalf("Expected no error, got: %q\n", err)
 	}
 
-	// Scan hosts
-	if err := scanAction(&out, tf, nil); err != nil {
+	// Scan hosts, on a network where none of them exists
+	n := scantest.NewNetwork()
+
+	if err := scanAction(context.Background(), &out, statusOptions{}, tf, nil, scan.Config{Dialer: n, Resolver: n}, outputOptions{}); err != nil {
 		t.Fatalf("Expected no error, got: %q\n", err)
 	}
 
@@ -225,3 +226,92 @@ func TestIntegration(t *testing.T) {
 		t.Errorf("Expected output: %q, got: %q instead\n", expectedOut, out.String())
 	}
 }
+
+func TestScanActionInterrupted(t *testing.T) {
+	tf, cleanup := setup(t, []string{"localhost"}, true)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var out bytes.Buffer
+
+	n := scantest.NewNetwork()
+	n.AddHost("localhost").TCP(22, scan.StateOpen)
+
+	err := scanAction(ctx, &out, statusOptions{}, tf, []int{22}, scan.Config{Dialer: n, Resolver: n}, outputOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected error %q, got: %v\n", context.Canceled, err)
+	}
+}
+
+func TestScanActionVerbose(t *testing.T) {
+	tf, cleanup := setup(t, []string{"web.test", "gone.test"}, true)
+	defer cleanup()
+
+	stopClock(t)
+
+	n := scantest.NewNetwork()
+	n.AddHost("web.test").WithLatency(5*time.Millisecond).TCP(22, scan.StateOpen)
+
+	cfg := scan.Config{Dialer: n, Resolver: n, Adaptive: true, Retries: 1}
+
+	var out, status bytes.Buffer
+
+	if err := scanAction(context.Background(), &out, statusOptions{out: &status, verbose: true}, tf, []int{22, 80}, cfg, outputOptions{}); err != nil {
+		t.Fatalf("Expected no error, got: %q\n", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(status.String()), "\n")
+
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines of verbose output, got %q\n", status.String())
+	}
+
+	if expected := "Scanning 2 hosts, 2 ports each, timeout 1s, adaptive down to 100ms, 1 retries"; lines[0] != expected {
+		t.Errorf("Expected %q, got %q instead\n", expected, lines[0])
+	}
+
+	// Hosts not found have no timing.
+	if !strings.HasPrefix(lines[1], "web.test: timeout 100ms, rtt ") || !strings.HasSuffix(lines[1], " from 2 answers") {
+		t.Errorf("Expected the timing of web.test, got %q instead\n", lines[1])
+	}
+
+	if strings.Contains(out.String(), "timeout") {
+		t.Errorf("Expected no timing in the results, got %q\n", out.String())
+	}
+}
+
+func TestSYNFallbacks(t *testing.T) {
+	addrs := []string{"192.0.2.1", "2001:db8::1"}
+
+	testCases := []struct {
+		name     string
+		cfg      scan.Config
+		tcpPorts int
+		expected int
+	}{
+		{"Connect", scan.Config{}, 1, 0},
+		{"SYN", scan.Config{Technique: scan.TechniqueSYN}, 1, 1},
+		{"FirstAddress", scan.Config{Technique: scan.TechniqueSYN, FirstAddress: true}, 1, 0},
+		{"UDPOnly", scan.Config{Technique: scan.TechniqueSYN}, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			errs := synFallbacks(addrs, tc.tcpPorts, tc.cfg)
+
+			if len(errs) != tc.expected {
+				t.Fatalf("Expected %d fallbacks, got %v instead\n", tc.expected, errs)
+			}
+
+			for _, err := range errs {
+				if !errors.Is(err, scan.ErrSYNUnavailable) || !strings.Contains(err.Error(), "2001:db8::1") {
+					t.Errorf("Expected SYN unavailable for 2001:db8::1, got %q\n", err)
+				}
+			}
+		})
+	}
+}
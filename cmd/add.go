@@ -28,9 +28,14 @@ import (
 
 // addCmd represents the add command
 var addCmd = &cobra.Command{
-	Use:          "add <host1>...<hostN>",
-	Aliases:      []string{"a"},
-	Short:        "Add new host(s) to the hosts list",
+	Use:     "add <host1>...<hostN>",
+	Aliases: []string{"a"},
+	Short:   "Add new host(s) to the hosts list",
+	Long: `Add new host(s) to the hosts list.
+
+Besides hostnames and IP addresses, hosts can be CIDR blocks such as
+10.0.0.0/24 or 2001:db8::/120, and ranges of addresses such as 10.0.0.1-50
+or 10.0.0.1-10.0.1.10. They are expanded to each address at scan time.`,
 	Args:         cobra.MinimumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
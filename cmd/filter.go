@@ -0,0 +1,181 @@
+/*
+Copyright © 2023 Still Learning LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+// scanSummary counts the hosts and port states of a scan.
+type scanSummary struct {
+	Hosts        int `json:"hosts"`
+	Up           int `json:"up"`
+	NotFound     int `json:"not_found"`
+	Open         int `json:"open"`
+	Closed       int `json:"closed"`
+	Filtered     int `json:"filtered"`
+	Unreachable  int `json:"unreachable"`
+	OpenFiltered int `json:"open_filtered"`
+}
+
+// newSummary counts the hosts and port states in results.
+func newSummary(results []scan.Results) scanSummary {
+	s := scanSummary{Hosts: len(results)}
+
+	for _, r := range results {
+		if r.NotFound {
+			s.NotFound++
+			continue
+		}
+
+		s.Up++
+
+		for _, p := range r.PortStates {
+			switch p.State {
+			case scan.StateOpen:
+				s.Open++
+			case scan.StateClosed:
+				s.Closed++
+			case scan.StateFiltered:
+				s.Filtered++
+			case scan.StateUnreachable:
+				s.Unreachable++
+			case scan.StateOpenFiltered:
+				s.OpenFiltered++
+			}
+		}
+	}
+
+	return s
+}
+
+// printSummary writes the summary line of the text output. The
+// open|filtered count only shows up for scans with UDP ports in that state.
+func printSummary(out io.Writer, s scanSummary, elapsed time.Duration) error {
+	openFiltered := ""
+	if s.OpenFiltered > 0 {
+		openFiltered = fmt.Sprintf(", %d open|filtered", s.OpenFiltered)
+	}
+
+	_, err := fmt.Fprintf(out, "%d hosts scanned (%d up, %d not found): %d open, %d closed, %d filtered, %d unreachable%s ports in %s\n",
+		s.Hosts, s.Up, s.NotFound, s.Open, s.Closed, s.Filtered, s.Unreachable, openFiltered, elapsed.Round(time.Millisecond))
+
+	return err
+}
+
+// filterResults keeps only the ports whose state is in states, the hosts
+// with at least one of those ports, and the hosts that were not found, so
+// they are reported like in the summary. A nil states keeps all the
+// results.
+func filterResults(results []scan.Results, states map[scan.State]bool) []scan.Results {
+	if states == nil {
+		return results
+	}
+
+	filtered := make([]scan.Results, 0, len(results))
+
+	for _, r := range results {
+		if r.NotFound {
+			filtered = append(filtered, r)
+			continue
+		}
+
+		var ports []scan.PortState
+
+		for _, p := range r.PortStates {
+			if states[p.State] {
+				ports = append(ports, p)
+			}
+		}
+
+		if len(ports) == 0 {
+			continue
+		}
+
+		r.PortStates = ports
+		filtered = append(filtered, r)
+	}
+
+	return filtered
+}
+
+// parseStates converts state names, as printed in the results, to a set of
+// states.
+func parseStates(names []string) (map[scan.State]bool, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	states := map[scan.State]bool{}
+
+	for _, name := range names {
+		var s scan.State
+
+		if err := s.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+			return nil, err
+		}
+
+		states[s] = true
+	}
+
+	return states, nil
+}
+
+// printByPort writes the text output grouped by port and state, listing
+// the hosts found in each TCP port, and then in each UDP port, along with
+// the address they were scanned on.
+func printByPort(out io.Writer, ports, udpPorts []int, results []scan.Results) error {
+	hosts := map[string][]string{}
+
+	for _, r := range results {
+		for _, p := range r.PortStates {
+			key := fmt.Sprintf("%d/%s %s", p.Port, p.Protocol, p.State)
+			hosts[key] = append(hosts[key], hostLabel(r.Host, p.Address))
+		}
+	}
+
+	message := ""
+
+	for _, proto := range []struct {
+		name  string
+		ports []int
+	}{{scan.ProtocolTCP, ports}, {scan.ProtocolUDP, udpPorts}} {
+		for _, port := range proto.ports {
+			name := ""
+			if svc := scan.LookupService(port, proto.name); svc != "" {
+				name = fmt.Sprintf(" (%s)", svc)
+			}
+
+			for _, st := range []scan.State{scan.StateOpen, scan.StateClosed, scan.StateFiltered, scan.StateUnreachable, scan.StateOpenFiltered} {
+				key := fmt.Sprintf("%d/%s %s", port, proto.name, st)
+				if len(hosts[key]) == 0 {
+					continue
+				}
+
+				message += fmt.Sprintf("%d/%s%s %s: %s\n", port, proto.name, name, st, strings.Join(hosts[key], ", "))
+			}
+		}
+	}
+
+	_, err := fmt.Fprint(out, message)
+	return err
+}
@@ -0,0 +1,146 @@
+/*
+Copyright © 2023 Still Learning LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+// checkNames lists the checks supported by the --check flag.
+var checkNames = []string{"tls"}
+
+// parseChecks enables the checks named in names in cfg.
+func parseChecks(names []string, cfg *scan.Config) error {
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case "tls":
+			cfg.CheckTLS = true
+		default:
+			return fmt.Errorf("unknown check %q, use one of %s", name, strings.Join(checkNames, ", "))
+		}
+	}
+
+	return nil
+}
+
+// parseSeverity converts a severity name to a scan.Severity. It returns
+// nil for an empty name.
+func parseSeverity(name string) (*scan.Severity, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	var s scan.Severity
+
+	if err := s.UnmarshalText([]byte(name)); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// parseFailOn converts the --fail-on severity name, which requires a check
+// reporting findings to be enabled in cfg. It returns nil for an empty name.
+func parseFailOn(name string, cfg scan.Config) (*scan.Severity, error) {
+	s, err := parseSeverity(name)
+	if err != nil || s == nil {
+		return s, err
+	}
+
+	if !cfg.CheckTLS {
+		return nil, errors.New("--fail-on requires a check reporting findings, such as --check tls")
+	}
+
+	return s, nil
+}
+
+// countFindings returns the number of findings in results with at least
+// the severity.
+func countFindings(results []scan.Results, severity scan.Severity) int {
+	n := 0
+
+	for _, r := range results {
+		for _, f := range r.Findings {
+			if f.Severity >= severity {
+				n++
+			}
+		}
+	}
+
+	return n
+}
+
+// printFindings writes the findings section of the text output. It writes
+// nothing when there are no findings.
+func printFindings(out io.Writer, results []scan.Results) error {
+	message := ""
+
+	for _, r := range results {
+		for _, f := range r.Findings {
+			message += fmt.Sprintf("\t%-8s %s:%d %s: %s\n", f.Severity, hostLabel(r.Host, f.Address), f.Port, f.ID, f.Message)
+		}
+	}
+
+	if message == "" {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(out, "Findings:\n%s\n", message)
+	return err
+}
+
+// findingsHeader names the columns of the findings section of the csv and
+// tsv output formats.
+var findingsHeader = []string{"host", "ip", "port", "check", "id", "severity", "message"}
+
+// writeFindingsCSV writes the findings section of the csv and tsv output
+// formats, after an empty line. It writes nothing when there are no
+// findings.
+func writeFindingsCSV(w *csv.Writer, results []scan.Results) error {
+	header := false
+
+	for _, r := range results {
+		for _, f := range r.Findings {
+			if !header {
+				if err := w.Write(nil); err != nil {
+					return err
+				}
+
+				if err := w.Write(findingsHeader); err != nil {
+					return err
+				}
+
+				header = true
+			}
+
+			row := []string{r.Host, f.Address, strconv.Itoa(f.Port), f.Check, f.ID, f.Severity.String(), f.Message}
+
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+// findingsReport returns the test report with two findings on host1.
+func findingsReport() scanReport {
+	r := testReport()
+	r.Results[0].Findings = []scan.Finding{
+		{Port: 22, Address: "10.0.0.1", Check: "tls", ID: scan.FindingCertExpiring, Severity: scan.SeverityMedium, Message: "certificate expires in 10 days, on 2023-11-22"},
+		{Port: 22, Address: "10.0.0.1", Check: "tls", ID: scan.FindingTLSWeakCipher, Severity: scan.SeverityHigh, Message: "weak cipher suites enabled: TLS_RSA_WITH_RC4_128_SHA"},
+	}
+
+	return r
+}
+
+func TestWriteFindings(t *testing.T) {
+	testCases := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: "text",
+			expected: "Findings:\n" +
+				"\tmedium   host1 (10.0.0.1):22 cert-expiring: certificate expires in 10 days, on 2023-11-22\n" +
+				"\thigh     host1 (10.0.0.1):22 tls-weak-cipher: weak cipher suites enabled: TLS_RSA_WITH_RC4_128_SHA\n\n" +
+				"2 hosts scanned",
+		},
+		{
+			format: "csv",
+			expected: "host2,,,,not found,,,,,,,,,,,,,,,,,\n\n" +
+				"host,ip,port,check,id,severity,message\n" +
+				"host1,10.0.0.1,22,tls,cert-expiring,medium,\"certificate expires in 10 days, on 2023-11-22\"\n" +
+				"host1,10.0.0.1,22,tls,tls-weak-cipher,high,weak cipher suites enabled: TLS_RSA_WITH_RC4_128_SHA\n",
+		},
+		{
+			format:   "json",
+			expected: `"findings": [`,
+		},
+		{
+			format: "ndjson",
+			expected: `{"type":"finding","host":"host1","finding":{"port":22,"address":"10.0.0.1","check":"tls","id":"cert-expiring","severity":"medium",` +
+				`"message":"certificate expires in 10 days, on 2023-11-22"}}`,
+		},
+		{
+			format: "nmap-xml",
+			expected: `<script id="pscan-findings" output="medium cert-expiring: certificate expires in 10 days, on 2023-11-22&#xA;` +
+				`high tls-weak-cipher: weak cipher suites enabled: TLS_RSA_WITH_RC4_128_SHA"></script>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.format, func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := writeReport(&out, findingsReport(), outputOptions{format: tc.format}); err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			if !strings.Contains(out.String(), tc.expected) {
+				t.Errorf("Expected output to contain %q, got:\n%s", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestCountFindings(t *testing.T) {
+	testCases := []struct {
+		severity string
+		expected int
+	}{
+		{severity: "low", expected: 2},
+		{severity: "medium", expected: 2},
+		{severity: "high", expected: 1},
+		{severity: "critical", expected: 0},
+	}
+
+	results := findingsReport().Results
+
+	for _, tc := range testCases {
+		s, err := parseSeverity(tc.severity)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %q\n", err)
+		}
+
+		if n := countFindings(results, *s); n != tc.expected {
+			t.Errorf("Expected %d findings at %s or higher, got %d instead\n", tc.expected, tc.severity, n)
+		}
+	}
+
+	if _, err := parseSeverity("urgent"); err == nil {
+		t.Error("Expected error for unknown severity, got nil")
+	}
+}
+
+func TestParseChecks(t *testing.T) {
+	var cfg scan.Config
+
+	if err := parseChecks([]string{"tls"}, &cfg); err != nil || !cfg.CheckTLS {
+		t.Errorf("Expected tls check enabled, got %t (%v)\n", cfg.CheckTLS, err)
+	}
+
+	if err := parseChecks([]string{"dns"}, &cfg); err == nil {
+		t.Error("Expected error for unknown check, got nil")
+	}
+}
+
+func TestParseFailOn(t *testing.T) {
+	if s, err := parseFailOn("", scan.Config{}); s != nil || err != nil {
+		t.Errorf("Expected no severity and no error, got %v (%v)\n", s, err)
+	}
+
+	if _, err := parseFailOn("high", scan.Config{}); err == nil {
+		t.Error("Expected error for --fail-on without a check, got nil")
+	}
+
+	s, err := parseFailOn("high", scan.Config{CheckTLS: true})
+	if err != nil || s == nil || *s != scan.SeverityHigh {
+		t.Errorf("Expected high severity, got %v (%v)\n", s, err)
+	}
+}
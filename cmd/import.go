@@ -0,0 +1,142 @@
+/*
+Copyright © 2023 Still Learning LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Dbaker1298/pScan/scan"
+	"github.com/spf13/cobra"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import hosts from other tools",
+	Long: `Import hosts from the output of other tools into the hosts list.
+
+Import nmap XML output with the nmap subcommand.`,
+}
+
+// importNmapCmd represents the import nmap command
+var importNmapCmd = &cobra.Command{
+	Use:   "nmap <file.xml>",
+	Short: "Import hosts from an nmap XML file",
+	Long: `Import the hosts of an nmap XML file into the hosts list.
+
+Hosts that nmap reported down and hosts already in the list are skipped.
+When a host of the file is not a valid host, nothing is imported.
+
+With --results, the port results found in the file are loaded too, and
+printed after the import.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostsFile, err := cmd.Flags().GetString("hosts-file")
+		if err != nil {
+			return err
+		}
+
+		showResults, err := cmd.Flags().GetBool("results")
+		if err != nil {
+			return err
+		}
+
+		return importNmapAction(os.Stdout, hostsFile, args[0], showResults)
+	},
+}
+
+// importNmapAction adds the hosts that are up in the nmap XML file to the
+// hosts list, and prints the results of the file when showResults is set.
+// Nothing is added when any of the hosts is invalid.
+func importNmapAction(out io.Writer, hostsFile, xmlFile string, showResults bool) error {
+	f, err := os.Open(xmlFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	run, err := decodeNmapXML(f)
+	if err != nil {
+		return err
+	}
+
+	hl := &scan.HostsList{}
+
+	if err := hl.Load(hostsFile); err != nil {
+		return err
+	}
+
+	// Report the changes once they are saved, so nothing is reported as
+	// added when a later host is invalid.
+	var report []string
+
+	// Hosts scanned by pScan on several addresses have one nmap host per
+	// address.
+	seen := map[string]bool{}
+
+	for _, h := range run.Hosts {
+		host := h.name()
+		if host == "" || seen[host] {
+			continue
+		}
+
+		seen[host] = true
+
+		if h.Status.State == "down" {
+			report = append(report, "Skipped down host: "+host)
+			continue
+		}
+
+		if err := hl.Add(host); err != nil {
+			if errors.Is(err, scan.ErrExists) {
+				report = append(report, "Skipped existing host: "+host)
+				continue
+			}
+
+			return err
+		}
+
+		report = append(report, "Added host: "+host)
+	}
+
+	if err := hl.Save(hostsFile); err != nil {
+		return err
+	}
+
+	for _, line := range report {
+		fmt.Fprintln(out, line)
+	}
+
+	if !showResults {
+		return nil
+	}
+
+	fmt.Fprintln(out)
+
+	return printResults(out, nmapResults(run))
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importNmapCmd)
+
+	importNmapCmd.Flags().Bool("results", false, "Also load and print the port results found in the file")
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2023 Still Learning LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/Dbaker1298/pScan/scan"
+	"github.com/spf13/viper"
+)
+
+// networkLimitConfig is an entry of the limits list in the config file,
+// such as:
+//
+//	limits:
+//	  - network: 10.20.0.0/16
+//	    rate: 50
+//	    burst: 5
+//	    max-concurrent: 10
+type networkLimitConfig struct {
+	Network       string `mapstructure:"network"`
+	Rate          int    `mapstructure:"rate"`
+	Burst         int    `mapstructure:"burst"`
+	MaxConcurrent int    `mapstructure:"max-concurrent"`
+}
+
+// networkLimits reads the per network limits from the limits key of the
+// config file.
+func networkLimits(v *viper.Viper) ([]scan.NetworkLimit, error) {
+	var entries []networkLimitConfig
+
+	if err := v.UnmarshalKey("limits", &entries); err != nil {
+		return nil, fmt.Errorf("invalid limits in config file: %w", err)
+	}
+
+	limits := make([]scan.NetworkLimit, 0, len(entries))
+
+	for _, e := range entries {
+		prefix, err := netip.ParsePrefix(e.Network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q in config file limits: %w", e.Network, err)
+		}
+
+		if e.Rate < 0 || e.Burst < 0 || e.MaxConcurrent < 0 {
+			return nil, fmt.Errorf("invalid limits for %s in config file: values cannot be negative", e.Network)
+		}
+
+		limits = append(limits, scan.NetworkLimit{
+			Prefix:        prefix.Masked(),
+			Rate:          e.Rate,
+			Burst:         e.Burst,
+			MaxConcurrent: e.MaxConcurrent,
+		})
+	}
+
+	return limits, nil
+}
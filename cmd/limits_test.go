@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Dbaker1298/pScan/scan"
+	"github.com/spf13/viper"
+)
+
+func TestNetworkLimits(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   string
+		expected []scan.NetworkLimit
+		errMsg   string
+	}{
+		{"None", "hosts-file: pScan.hosts\n", []scan.NetworkLimit{}, ""},
+		{
+			"Limits",
+			`limits:
+  - network: 10.20.0.0/16
+    rate: 50
+    burst: 5
+    max-concurrent: 10
+  - network: 192.168.1.7/24
+    max-concurrent: 1
+`,
+			[]scan.NetworkLimit{
+				{Prefix: netip.MustParsePrefix("10.20.0.0/16"), Rate: 50, Burst: 5, MaxConcurrent: 10},
+				{Prefix: netip.MustParsePrefix("192.168.1.0/24"), MaxConcurrent: 1},
+			},
+			"",
+		},
+		{"InvalidNetwork", "limits:\n  - network: 10.20.0.0\n    rate: 50\n", nil, `invalid network "10.20.0.0"`},
+		{"Negative", "limits:\n  - network: 10.20.0.0/16\n    rate: -1\n", nil, "cannot be negative"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			v := viper.New()
+			v.SetConfigType("yaml")
+
+			if err := v.ReadConfig(strings.NewReader(tc.config)); err != nil {
+				t.Fatalf("Expected no error reading the config, got: %q\n", err)
+			}
+
+			limits, err := networkLimits(v)
+
+			if tc.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+					t.Fatalf("Expected error containing %q, got: %v\n", tc.errMsg, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			if !reflect.DeepEqual(limits, tc.expected) {
+				t.Errorf("Expected limits %+v, got %+v instead\n", tc.expected, limits)
+			}
+		})
+	}
+}
@@ -0,0 +1,518 @@
+/*
+Copyright © 2023 Still Learning LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+// The types below map the subset of the nmap XML output format used by
+// pScan. See https://nmap.org/book/nmap-dtd.html for the full format.
+
+type nmapRun struct {
+	XMLName          xml.Name       `xml:"nmaprun"`
+	Scanner          string         `xml:"scanner,attr"`
+	Args             string         `xml:"args,attr,omitempty"`
+	Start            int64          `xml:"start,attr"`
+	StartStr         string         `xml:"startstr,attr,omitempty"`
+	Version          string         `xml:"version,attr"`
+	XMLOutputVersion string         `xml:"xmloutputversion,attr"`
+	ScanInfo         []nmapScanInfo `xml:"scaninfo"`
+	Hosts            []nmapHost     `xml:"host"`
+	RunStats         nmapRunStats   `xml:"runstats"`
+}
+
+type nmapScanInfo struct {
+	Type        string `xml:"type,attr"`
+	Protocol    string `xml:"protocol,attr"`
+	NumServices int    `xml:"numservices,attr"`
+	Services    string `xml:"services,attr"`
+}
+
+type nmapHost struct {
+	Status    nmapStatus     `xml:"status"`
+	Addresses []nmapAddress  `xml:"address"`
+	Hostnames []nmapHostname `xml:"hostnames>hostname"`
+	Ports     []nmapPort     `xml:"ports>port"`
+}
+
+type nmapStatus struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+}
+
+type nmapHostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type nmapPort struct {
+	Protocol string       `xml:"protocol,attr"`
+	PortID   int          `xml:"portid,attr"`
+	State    nmapState    `xml:"state"`
+	Service  *nmapService `xml:"service"`
+	Scripts  []nmapScript `xml:"script"`
+}
+
+type nmapState struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapService struct {
+	Name    string `xml:"name,attr"`
+	Product string `xml:"product,attr,omitempty"`
+	Version string `xml:"version,attr,omitempty"`
+	Method  string `xml:"method,attr"`
+	Conf    int    `xml:"conf,attr"`
+}
+
+type nmapScript struct {
+	ID     string `xml:"id,attr"`
+	Output string `xml:"output,attr"`
+}
+
+type nmapRunStats struct {
+	Finished nmapFinished  `xml:"finished"`
+	Hosts    nmapHostStats `xml:"hosts"`
+}
+
+type nmapFinished struct {
+	Time    int64   `xml:"time,attr"`
+	TimeStr string  `xml:"timestr,attr,omitempty"`
+	Elapsed float64 `xml:"elapsed,attr"`
+}
+
+type nmapHostStats struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+// nmapTimeFormat is the format nmap uses for the startstr and timestr
+// attributes.
+const nmapTimeFormat = "Mon Jan _2 15:04:05 2006"
+
+// writeNmapXML writes the report in the nmap XML output format.
+func writeNmapXML(out io.Writer, r scanReport) error {
+	ports := make([]string, 0, len(r.Ports))
+	for _, p := range r.Ports {
+		ports = append(ports, strconv.Itoa(p))
+	}
+
+	udpPorts := make([]string, 0, len(r.UDPPorts))
+	for _, p := range r.UDPPorts {
+		udpPorts = append(udpPorts, strconv.Itoa(p))
+	}
+
+	// nmap calls connect scans "connect", and SYN scans "syn" too.
+	technique := r.Technique
+	if technique == "" {
+		technique = scan.TechniqueConnect
+	}
+
+	args := fmt.Sprintf("%s scan", r.Scanner)
+	if technique != scan.TechniqueConnect {
+		args += " --technique " + technique
+	}
+
+	// The T: and U: prefixes are only written for the protocols scanned,
+	// and T: only along with U:.
+	var spec []string
+
+	if len(ports) > 0 {
+		spec = append(spec, ports...)
+
+		if len(udpPorts) > 0 {
+			spec[0] = "T:" + spec[0]
+		}
+	}
+
+	if len(udpPorts) > 0 {
+		spec = append(spec, "U:"+udpPorts[0])
+		spec = append(spec, udpPorts[1:]...)
+	}
+
+	run := nmapRun{
+		Scanner:          r.Scanner,
+		Args:             fmt.Sprintf("%s --ports %s", args, strings.Join(spec, ",")),
+		Start:            r.Start.Unix(),
+		StartStr:         r.Start.Format(nmapTimeFormat),
+		Version:          r.Version,
+		XMLOutputVersion: "1.05",
+		RunStats: nmapRunStats{
+			Finished: nmapFinished{
+				Time:    r.End.Unix(),
+				TimeStr: r.End.Format(nmapTimeFormat),
+				Elapsed: r.Elapsed.Seconds(),
+			},
+		},
+	}
+
+	if len(ports) > 0 {
+		run.ScanInfo = append(run.ScanInfo, nmapScanInfo{
+			Type:        technique,
+			Protocol:    scan.ProtocolTCP,
+			NumServices: len(ports),
+			Services:    strings.Join(ports, ","),
+		})
+	}
+
+	if len(udpPorts) > 0 {
+		run.ScanInfo = append(run.ScanInfo, nmapScanInfo{
+			Type:        "udp",
+			Protocol:    scan.ProtocolUDP,
+			NumServices: len(udpPorts),
+			Services:    strings.Join(udpPorts, ","),
+		})
+	}
+
+	for _, res := range r.Results {
+		for _, h := range nmapHosts(res) {
+			if h.Status.State == "down" {
+				run.RunStats.Hosts.Down++
+			} else {
+				run.RunStats.Hosts.Up++
+			}
+
+			run.Hosts = append(run.Hosts, h)
+		}
+	}
+
+	run.RunStats.Hosts.Total = run.RunStats.Hosts.Up + run.RunStats.Hosts.Down
+
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(run); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(out)
+	return err
+}
+
+// nmapHosts returns the nmap hosts of the results. nmap reports each
+// address as its own host, so hosts scanned on several addresses give one
+// host per address, with the ports scanned on it.
+func nmapHosts(res scan.Results) []nmapHost {
+	var (
+		addrs []string
+		ports = map[string][]scan.PortState{}
+	)
+
+	for _, p := range res.PortStates {
+		if _, ok := ports[p.Address]; !ok {
+			addrs = append(addrs, p.Address)
+		}
+
+		ports[p.Address] = append(ports[p.Address], p)
+	}
+
+	if len(addrs) <= 1 {
+		return []nmapHost{nmapHostFor(res, res.Addrs, res.PortStates)}
+	}
+
+	hosts := make([]nmapHost, 0, len(addrs))
+	for _, a := range addrs {
+		hosts = append(hosts, nmapHostFor(res, []string{a}, ports[a]))
+	}
+
+	return hosts
+}
+
+// nmapHostFor returns the nmap host with the addresses addrs and the ports
+// of the results.
+func nmapHostFor(res scan.Results, addrs []string, ports []scan.PortState) nmapHost {
+	h := nmapHost{Status: nmapStatus{State: "up", Reason: "user-set"}}
+
+	if res.NotFound {
+		h.Status = nmapStatus{State: "down", Reason: "no-dns"}
+	}
+
+	for _, a := range addrs {
+		h.Addresses = append(h.Addresses, nmapAddress{Addr: a, AddrType: addrType(a)})
+	}
+
+	if _, err := netip.ParseAddr(res.Host); err != nil {
+		h.Hostnames = append(h.Hostnames, nmapHostname{Name: res.Host, Type: "user"})
+	} else if len(h.Addresses) == 0 {
+		h.Addresses = append(h.Addresses, nmapAddress{Addr: res.Host, AddrType: addrType(res.Host)})
+	}
+
+	for _, p := range ports {
+		np := nmapPort{
+			Protocol: p.Protocol,
+			PortID:   p.Port,
+			State:    nmapState{State: nmapPortState(p.State), Reason: p.Reason},
+		}
+
+		switch {
+		case p.Service != nil:
+			np.Service = &nmapService{
+				Name:    p.Service.Name,
+				Product: p.Service.Product,
+				Version: p.Service.Version,
+				Method:  "probed",
+				Conf:    10,
+			}
+		case scan.LookupService(p.Port, p.Protocol) != "":
+			np.Service = &nmapService{Name: scan.LookupService(p.Port, p.Protocol), Method: "table", Conf: 3}
+		}
+
+		if p.Banner != "" {
+			np.Scripts = append(np.Scripts, nmapScript{ID: "banner", Output: p.Banner})
+		}
+
+		if p.TLS != nil {
+			np.Scripts = append(np.Scripts, nmapScript{ID: "ssl-cert", Output: sslCertOutput(p.TLS)})
+		}
+
+		if p.HTTP != nil {
+			np.Scripts = append(np.Scripts, nmapScript{ID: "http-title", Output: httpTitleOutput(p.HTTP)})
+
+			if p.HTTP.Server != "" {
+				np.Scripts = append(np.Scripts, nmapScript{ID: "http-server-header", Output: p.HTTP.Server})
+			}
+		}
+
+		// Findings only come from checks on TCP ports.
+		if findings := findingsOutput(res.Findings, p.Address, p.Port); findings != "" && p.Protocol == scan.ProtocolTCP {
+			np.Scripts = append(np.Scripts, nmapScript{ID: "pscan-findings", Output: findings})
+		}
+
+		h.Ports = append(h.Ports, np)
+	}
+
+	return h
+}
+
+// sslCertOutput describes the certificate the way nmap's ssl-cert script
+// does.
+func sslCertOutput(info *scan.TLSInfo) string {
+	names := make([]string, 0, len(info.SANs))
+
+	for _, san := range info.SANs {
+		if _, err := netip.ParseAddr(san); err == nil {
+			names = append(names, "IP Address:"+san)
+		} else {
+			names = append(names, "DNS:"+san)
+		}
+	}
+
+	const timeFormat = "2006-01-02T15:04:05"
+
+	return fmt.Sprintf("Subject: %s\nSubject Alternative Name: %s\nIssuer: %s\nNot valid before: %s\nNot valid after:  %s",
+		info.Subject, strings.Join(names, ", "), info.Issuer,
+		info.NotBefore.UTC().Format(timeFormat), info.NotAfter.UTC().Format(timeFormat))
+}
+
+// httpTitleOutput describes the page the way nmap's http-title script does.
+func httpTitleOutput(info *scan.HTTPInfo) string {
+	switch {
+	case info.Location != "":
+		return "Did not follow redirect to " + info.Location
+	case info.Title != "":
+		return info.Title
+	}
+
+	return "Site doesn't have a title."
+}
+
+// findingsOutput lists the findings for port on the address addr, one per
+// line. Findings and ports without an address match any address.
+func findingsOutput(findings []scan.Finding, addr string, port int) string {
+	var lines []string
+
+	for _, f := range findings {
+		if f.Port == port && (f.Address == "" || addr == "" || f.Address == addr) {
+			lines = append(lines, fmt.Sprintf("%s %s: %s", f.Severity, f.ID, f.Message))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// addrType returns the nmap address type of addr.
+func addrType(addr string) string {
+	if a, err := netip.ParseAddr(addr); err == nil && a.Is6() && !a.Is4In6() {
+		return "ipv6"
+	}
+
+	return "ipv4"
+}
+
+// nmapPortState returns the nmap name of the port state. nmap has no
+// unreachable state, it reports those ports as filtered.
+func nmapPortState(s scan.State) string {
+	if s == scan.StateUnreachable {
+		return scan.StateFiltered.String()
+	}
+
+	return s.String()
+}
+
+// decodeNmapXML decodes an nmap XML document.
+func decodeNmapXML(in io.Reader) (nmapRun, error) {
+	var run nmapRun
+
+	if err := xml.NewDecoder(in).Decode(&run); err != nil {
+		return run, fmt.Errorf("invalid nmap XML: %w", err)
+	}
+
+	return run, nil
+}
+
+// readNmapXML reads the hosts and port results from an nmap XML document.
+// Each host is named after its user supplied hostname when it has one, or
+// its first IP address otherwise.
+func readNmapXML(in io.Reader) ([]scan.Results, error) {
+	run, err := decodeNmapXML(in)
+	if err != nil {
+		return nil, err
+	}
+
+	return nmapResults(run), nil
+}
+
+// nmapResults converts the hosts of an nmap run to results. pScan writes
+// hosts scanned on several addresses as one host per address, so the hosts
+// of the same name are merged back into one result.
+func nmapResults(run nmapRun) []scan.Results {
+	var (
+		results []scan.Results
+		index   = map[string]int{}
+	)
+
+	for _, h := range run.Hosts {
+		name := h.name()
+		if name == "" {
+			continue
+		}
+
+		addrs := h.addrs()
+
+		var ports []scan.PortState
+
+		for _, p := range h.Ports {
+			if p.Protocol != scan.ProtocolTCP && p.Protocol != scan.ProtocolUDP {
+				continue
+			}
+
+			ps := scan.PortState{
+				Port:     p.PortID,
+				Protocol: p.Protocol,
+				State:    portStateFromNmap(p.State.State),
+				Reason:   p.State.Reason,
+			}
+
+			if len(addrs) > 0 {
+				ps.Address = addrs[0]
+			}
+
+			// Services guessed from the port number carry no information.
+			if p.Service != nil && p.Service.Method == "probed" {
+				ps.Service = &scan.Service{Name: p.Service.Name, Product: p.Service.Product, Version: p.Service.Version}
+			}
+
+			for _, script := range p.Scripts {
+				if script.ID == "banner" {
+					ps.Banner = script.Output
+				}
+			}
+
+			ports = append(ports, ps)
+		}
+
+		if i, ok := index[name]; ok {
+			results[i].Addrs = append(results[i].Addrs, addrs...)
+			results[i].PortStates = append(results[i].PortStates, ports...)
+
+			continue
+		}
+
+		index[name] = len(results)
+		results = append(results, scan.Results{
+			Host:       name,
+			NotFound:   h.Status.State == "down" && h.Status.Reason == "no-dns",
+			Addrs:      addrs,
+			PortStates: ports,
+		})
+	}
+
+	return results
+}
+
+// portStateFromNmap converts an nmap port state to a scan.State. Other
+// ambiguous states, such as closed|filtered, are reported as filtered.
+func portStateFromNmap(state string) scan.State {
+	switch state {
+	case "open":
+		return scan.StateOpen
+	case "closed":
+		return scan.StateClosed
+	case "open|filtered":
+		return scan.StateOpenFiltered
+	}
+
+	return scan.StateFiltered
+}
+
+// addrs returns the IP addresses of the host.
+func (h nmapHost) addrs() []string {
+	var addrs []string
+
+	for _, a := range h.Addresses {
+		if a.AddrType == "ipv4" || a.AddrType == "ipv6" {
+			addrs = append(addrs, a.Addr)
+		}
+	}
+
+	return addrs
+}
+
+// name returns the user supplied hostname of the host when it has one, or
+// its first IP address otherwise. It is empty when the host has neither.
+func (h nmapHost) name() string {
+	for _, hn := range h.Hostnames {
+		if hn.Type == "user" {
+			return hn.Name
+		}
+	}
+
+	if addrs := h.addrs(); len(addrs) > 0 {
+		return addrs[0]
+	}
+
+	return ""
+}
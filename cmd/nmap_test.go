@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+func TestWriteNmapXML(t *testing.T) {
+	var out bytes.Buffer
+
+	if err := writeReport(&out, testReport(), outputOptions{format: "nmap-xml"}); err != nil {
+		t.Fatalf("Expected no error, got: %q\n", err)
+	}
+
+	for _, s := range []string{
+		`<nmaprun scanner="pScan" args="pScan scan --ports 22,80"`,
+		`<scaninfo type="connect" protocol="tcp" numservices="2" services="22,80"></scaninfo>`,
+		`<address addr="10.0.0.1" addrtype="ipv4"></address>`,
+		`<hostname name="host1" type="user"></hostname>`,
+		`<port protocol="tcp" portid="22">`,
+		`<state state="open" reason="syn-ack"></state>`,
+		`<service name="ssh" product="OpenSSH" version="9.3" method="probed" conf="10"></service>`,
+		`<service name="http" method="table" conf="3"></service>`,
+		`<script id="banner" output="SSH-2.0-OpenSSH_9.3"></script>`,
+		`<status state="down" reason="no-dns"></status>`,
+		`<hosts up="1" down="1" total="2"></hosts>`,
+	} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("Expected output to contain %q, got:\n%s", s, out.String())
+		}
+	}
+
+	// Reading the output back gives the original results.
+	results, err := readNmapXML(&out)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %q\n", err)
+	}
+
+	// Ports are read back on the address of their host.
+	expected := testReport().Results
+	for i := range expected[0].PortStates {
+		expected[0].PortStates[i].Latency = 0
+		expected[0].PortStates[i].Address = "10.0.0.1"
+	}
+
+	// nmap has no notion of pScan probes.
+	expected[0].PortStates[0].Service.Probe = ""
+
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected results %+v, got %+v instead\n", expected, results)
+	}
+}
+
+func TestWriteNmapXMLProtocols(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ports    []int
+		udpPorts []int
+		expected []string
+		absent   string
+	}{
+		{
+			name:     "TCPAndUDP",
+			ports:    []int{22, 80},
+			udpPorts: []int{53, 161},
+			expected: []string{
+				`args="pScan scan --ports T:22,80,U:53,161"`,
+				`<scaninfo type="connect" protocol="tcp" numservices="2" services="22,80"></scaninfo>`,
+				`<scaninfo type="udp" protocol="udp" numservices="2" services="53,161"></scaninfo>`,
+			},
+		},
+		{
+			name:     "UDPOnly",
+			udpPorts: []int{53},
+			expected: []string{
+				`args="pScan scan --ports U:53"`,
+				`<scaninfo type="udp" protocol="udp" numservices="1" services="53"></scaninfo>`,
+			},
+			absent: `protocol="tcp"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			r := testReport()
+			r.Ports, r.UDPPorts = tc.ports, tc.udpPorts
+			r.Results = []scan.Results{{Host: "host1", Addrs: []string{"10.0.0.1"}}}
+
+			var out bytes.Buffer
+
+			if err := writeReport(&out, r, outputOptions{format: "nmap-xml"}); err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			for _, s := range tc.expected {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("Expected output to contain %q, got:\n%s", s, out.String())
+				}
+			}
+
+			if tc.absent != "" && strings.Contains(out.String(), tc.absent) {
+				t.Errorf("Expected output without %q, got:\n%s", tc.absent, out.String())
+			}
+		})
+	}
+}
+
+func TestImportNmapAction(t *testing.T) {
+	tf, cleanup := setup(t, []string{"host1"}, true)
+	defer cleanup()
+
+	xmlFile := filepath.Join(t.TempDir(), "scan.xml")
+
+	doc := `<?xml version="1.0"?>
+<nmaprun scanner="nmap" args="nmap -p 22 host1 10.0.0.2" start="1699783200" version="7.94" xmloutputversion="1.05">
+  <host>
+    <status state="up" reason="syn-ack"/>
+    <address addr="10.0.0.1" addrtype="ipv4"/>
+    <hostnames><hostname name="host1" type="user"/></hostnames>
+    <ports><port protocol="tcp" portid="22"><state state="open" reason="syn-ack"/></port></ports>
+  </host>
+  <host>
+    <status state="up" reason="echo-reply"/>
+    <address addr="10.0.0.2" addrtype="ipv4"/>
+    <address addr="00:11:22:33:44:55" addrtype="mac"/>
+    <hostnames><hostname name="db.example.com" type="PTR"/></hostnames>
+    <ports><port protocol="tcp" portid="22"><state state="open|filtered" reason="no-response"/></port></ports>
+  </host>
+  <host>
+    <status state="down" reason="no-response"/>
+    <address addr="10.0.0.3" addrtype="ipv4"/>
+  </host>
+</nmaprun>`
+
+	if err := os.WriteFile(xmlFile, []byte(doc), 0o644); err != nil {
+		t.Fatalf("failed to write XML file: %v", err)
+	}
+
+	var out bytes.Buffer
+
+	if err := importNmapAction(&out, tf, xmlFile, false); err != nil {
+		t.Fatalf("Expected no error, got: %q\n", err)
+	}
+
+	expectedOut := "Skipped existing host: host1\n" +
+		"Added host: 10.0.0.2\n" +
+		"Skipped down host: 10.0.0.3\n"
+
+	if out.String() != expectedOut {
+		t.Errorf("Expected output: %q, got: %q instead\n", expectedOut, out.String())
+	}
+
+	hl := &scan.HostsList{}
+	if err := hl.Load(tf); err != nil {
+		t.Fatalf("failed to load hosts list: %v", err)
+	}
+
+	if !reflect.DeepEqual(hl.Hosts, []string{"host1", "10.0.0.2"}) {
+		t.Errorf("Expected hosts %v, got %v instead\n", []string{"host1", "10.0.0.2"}, hl.Hosts)
+	}
+}
+
+// Test that hosts written by pScan on several addresses are imported once,
+// with the results of every address.
+func TestImportNmapActionResults(t *testing.T) {
+	tf, cleanup := setup(t, nil, false)
+	defer cleanup()
+
+	r := testReport()
+	r.Results[0].Addrs = []string{"10.0.0.1", "2001:db8::1"}
+	r.Results[0].PortStates = []scan.PortState{
+		{Port: 22, Protocol: scan.ProtocolTCP, Address: "10.0.0.1", State: scan.StateOpen, Reason: scan.ReasonSynAck},
+		{Port: 22, Protocol: scan.ProtocolTCP, Address: "2001:db8::1", State: scan.StateClosed, Reason: scan.ReasonConnRefused},
+	}
+
+	var doc bytes.Buffer
+
+	if err := writeReport(&doc, r, outputOptions{format: "nmap-xml"}); err != nil {
+		t.Fatalf("Expected no error, got: %q\n", err)
+	}
+
+	xmlFile := filepath.Join(t.TempDir(), "scan.xml")
+
+	if err := os.WriteFile(xmlFile, doc.Bytes(), 0o644); err != nil {
+		t.Fatalf("failed to write XML file: %v", err)
+	}
+
+	var out bytes.Buffer
+
+	if err := importNmapAction(&out, tf, xmlFile, true); err != nil {
+		t.Fatalf("Expected no error, got: %q\n", err)
+	}
+
+	expectedOut := "Added host: host1\n" +
+		"Skipped down host: host2\n" +
+		"\n" +
+		"host1:\n" +
+		"\t10.0.0.1:22: open (syn-ack)\n" +
+		"\t[2001:db8::1]:22: closed (conn-refused)\n" +
+		"\n" +
+		"host2: Host not found\n" +
+		"\n"
+
+	if out.String() != expectedOut {
+		t.Errorf("Expected output: %q, got: %q instead\n", expectedOut, out.String())
+	}
+}
+
+func TestImportNmapActionInvalidHost(t *testing.T) {
+	tf, cleanup := setup(t, []string{"host1"}, true)
+	defer cleanup()
+
+	xmlFile := filepath.Join(t.TempDir(), "scan.xml")
+
+	doc := `<?xml version="1.0"?>
+<nmaprun scanner="nmap">
+  <host>
+    <status state="up" reason="syn-ack"/>
+    <address addr="10.0.0.2" addrtype="ipv4"/>
+  </host>
+  <host>
+    <status state="up" reason="user-set"/>
+    <hostnames><hostname name="10.0.0.9-1" type="user"/></hostnames>
+  </host>
+</nmaprun>`
+
+	if err := os.WriteFile(xmlFile, []byte(doc), 0o644); err != nil {
+		t.Fatalf("failed to write XML file: %v", err)
+	}
+
+	var out bytes.Buffer
+
+	if err := importNmapAction(&out, tf, xmlFile, false); err == nil {
+		t.Fatal("Expected an error for the invalid host, got nil")
+	}
+
+	if out.Len() != 0 {
+		t.Errorf("Expected no output, got: %q\n", out.String())
+	}
+
+	hl := &scan.HostsList{}
+	if err := hl.Load(tf); err != nil {
+		t.Fatalf("failed to load hosts list: %v", err)
+	}
+
+	if !reflect.DeepEqual(hl.Hosts, []string{"host1"}) {
+		t.Errorf("Expected hosts %v, got %v instead\n", []string{"host1"}, hl.Hosts)
+	}
+}
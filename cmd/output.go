@@ -0,0 +1,411 @@
+/*
+Copyright © 2023 Still Learning LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+// outputOptions defines how the scan results are written.
+type outputOptions struct {
+	// format is one of the keys in outputFormats. Empty means text.
+	format string
+	// template, when set, renders the results instead of format.
+	template *template.Template
+	// states, when set, keeps only the ports in one of these states.
+	states map[scan.State]bool
+	// groupBy is "port" to list the hosts per port, instead of the ports
+	// per host, in the text format.
+	groupBy string
+	// failOn, when set, makes the scan fail once the results are written
+	// if any finding has at least this severity.
+	failOn *scan.Severity
+}
+
+// scanMetadata describes a scan run.
+type scanMetadata struct {
+	Scanner     string        `json:"scanner"`
+	Version     string        `json:"version"`
+	Start       time.Time     `json:"start"`
+	End         time.Time     `json:"end"`
+	Elapsed     time.Duration `json:"elapsed_ns"`
+	Technique   string        `json:"technique,omitempty"`
+	Ports       []int         `json:"ports"`
+	UDPPorts    []int         `json:"udp_ports,omitempty"`
+	Interrupted bool          `json:"interrupted"`
+	Summary     scanSummary   `json:"summary"`
+}
+
+// scanReport holds the scan results along with the scan metadata. It is the
+// document written by the json output format.
+type scanReport struct {
+	scanMetadata
+	Results []scan.Results `json:"hosts"`
+}
+
+// outputFormats maps the --output values to the functions writing them.
+var outputFormats = map[string]func(io.Writer, scanReport) error{
+	"text":     writeByHost(newTextWriter),
+	"json":     writeJSON,
+	"ndjson":   writeByHost(newNDJSONWriter),
+	"csv":      writeByHost(newCSVWriter(',')),
+	"tsv":      writeByHost(newCSVWriter('\t')),
+	"nmap-xml": writeNmapXML,
+}
+
+// hostWriter writes the results of a scan one host at a time, so the output
+// formats that allow it show each host as soon as it is scanned.
+type hostWriter struct {
+	// host writes the results of a single host.
+	host func(scan.Results) error
+	// finish writes what follows the hosts once the scan is done, such as
+	// the findings and the summary.
+	finish func(scanReport) error
+}
+
+// hostFormats maps the --output values that can be written one host at a
+// time to the functions starting their hostWriter.
+var hostFormats = map[string]func(io.Writer) (*hostWriter, error){
+	"text":   newTextWriter,
+	"ndjson": newNDJSONWriter,
+	"csv":    newCSVWriter(','),
+	"tsv":    newCSVWriter('\t'),
+}
+
+// writeByHost returns an output format writing the whole report with the
+// hostWriter started by start.
+func writeByHost(start func(io.Writer) (*hostWriter, error)) func(io.Writer, scanReport) error {
+	return func(out io.Writer, r scanReport) error {
+		w, err := start(out)
+		if err != nil {
+			return err
+		}
+
+		for _, res := range r.Results {
+			if err := w.host(res); err != nil {
+				return err
+			}
+		}
+
+		return w.finish(r)
+	}
+}
+
+// reportWriter writes the report of a scan while it runs. Hosts are written
+// as they come for the formats in hostFormats, and the other formats wait
+// for the whole report.
+type reportWriter struct {
+	out  io.Writer
+	opts outputOptions
+	// hosts is nil when the output needs the whole report: the json and
+	// nmap-xml formats, templates and the grouping by port.
+	hosts *hostWriter
+}
+
+// newReportWriter returns a reportWriter for the output selected by opts.
+func newReportWriter(out io.Writer, opts outputOptions) (*reportWriter, error) {
+	w := &reportWriter{out: out, opts: opts}
+
+	format := opts.format
+	if format == "" {
+		format = "text"
+	}
+
+	start, ok := hostFormats[format]
+	if !ok || opts.template != nil || opts.groupBy == "port" {
+		return w, nil
+	}
+
+	var err error
+	w.hosts, err = start(out)
+
+	return w, err
+}
+
+// host writes the results of a host that is done, with the ports filtered
+// by the --state flags, unless the output waits for the whole report.
+func (w *reportWriter) host(r scan.Results) error {
+	if w.hosts == nil {
+		return nil
+	}
+
+	filtered := filterResults([]scan.Results{r}, w.opts.states)
+	if len(filtered) == 0 {
+		return nil
+	}
+
+	return w.hosts.host(filtered[0])
+}
+
+// finish writes the end of the report, or the whole report when the output
+// waits for it.
+func (w *reportWriter) finish(r scanReport) error {
+	if w.hosts == nil {
+		return writeReport(w.out, r, w.opts)
+	}
+
+	r.Summary = newSummary(r.Results)
+	r.Results = filterResults(r.Results, w.opts.states)
+
+	return w.hosts.finish(r)
+}
+
+// formatNames returns the supported output formats, sorted.
+func formatNames() string {
+	names := make([]string, 0, len(outputFormats))
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, "|")
+}
+
+// checkFormat verifies format is a supported output format.
+func checkFormat(format string) error {
+	if _, ok := outputFormats[format]; !ok {
+		return fmt.Errorf("unknown output format %q, use one of %s", format, formatNames())
+	}
+
+	return nil
+}
+
+// writeReport writes the report in the format selected by opts.
+// The summary always counts all the results, even when some are filtered
+// out.
+func writeReport(out io.Writer, r scanReport, opts outputOptions) error {
+	r.Summary = newSummary(r.Results)
+	r.Results = filterResults(r.Results, opts.states)
+
+	if opts.template != nil {
+		return writeTemplate(out, opts.template, r)
+	}
+
+	format := opts.format
+	if format == "" {
+		format = "text"
+	}
+
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+
+	if opts.groupBy == "port" {
+		if format != "text" {
+			return fmt.Errorf("grouping by port requires the text output, not %q", format)
+		}
+
+		if err := printByPort(out, r.Ports, r.UDPPorts, r.Results); err != nil {
+			return err
+		}
+
+		if err := printFindings(out, r.Results); err != nil {
+			return err
+		}
+
+		return printSummary(out, r.Summary, r.Elapsed)
+	}
+
+	return outputFormats[format](out, r)
+}
+
+// writeJSON writes the report as a single indented JSON document.
+func writeJSON(out io.Writer, r scanReport) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(r)
+}
+
+// ndjsonEvent is a single line of the ndjson output format. Type is "port"
+// for each scanned port, "finding" for each finding, "host" once a host is
+// done and "scan" at the end.
+type ndjsonEvent struct {
+	Type     string `json:"type"`
+	Host     string `json:"host,omitempty"`
+	NotFound bool   `json:"not_found,omitempty"`
+	*scan.PortState
+	Finding *scan.Finding `json:"finding,omitempty"`
+	Timing  *scan.Timing  `json:"timing,omitempty"`
+	Scan    *scanMetadata `json:"scan,omitempty"`
+}
+
+// newTextWriter starts the text output, with the ports of each host, then
+// the findings and the summary line.
+func newTextWriter(out io.Writer) (*hostWriter, error) {
+	return &hostWriter{
+		host: func(res scan.Results) error {
+			return printResults(out, []scan.Results{res})
+		},
+		finish: func(r scanReport) error {
+			if err := printFindings(out, r.Results); err != nil {
+				return err
+			}
+
+			return printSummary(out, r.Summary, r.Elapsed)
+		},
+	}, nil
+}
+
+// newNDJSONWriter starts the ndjson output, writing the events of each host
+// as soon as it is done.
+func newNDJSONWriter(out io.Writer) (*hostWriter, error) {
+	enc := json.NewEncoder(out)
+
+	return &hostWriter{
+		host: func(res scan.Results) error {
+			for i := range res.PortStates {
+				if err := enc.Encode(ndjsonEvent{Type: "port", Host: res.Host, PortState: &res.PortStates[i]}); err != nil {
+					return err
+				}
+			}
+
+			for i := range res.Findings {
+				if err := enc.Encode(ndjsonEvent{Type: "finding", Host: res.Host, Finding: &res.Findings[i]}); err != nil {
+					return err
+				}
+			}
+
+			return enc.Encode(ndjsonEvent{Type: "host", Host: res.Host, NotFound: res.NotFound, Timing: res.Timing})
+		},
+		finish: func(r scanReport) error {
+			return enc.Encode(ndjsonEvent{Type: "scan", Scan: &r.scanMetadata})
+		},
+	}, nil
+}
+
+// csvHeader names the columns of the csv and tsv output formats.
+var csvHeader = []string{
+	"host", "ip", "port", "protocol", "state", "reason", "latency_ms", "service", "banner", "product", "version",
+	"tls_version", "tls_cipher", "cert_subject", "cert_issuer", "cert_days_left", "cert_verified",
+	"http_status", "http_server", "http_title", "http_location", "http_security_headers",
+}
+
+// newCSVWriter returns the function starting the csv output, with fields
+// separated by comma. The header is written right away, and the rows of
+// each host are flushed as soon as it is done. The findings follow in their
+// own section.
+func newCSVWriter(comma rune) func(io.Writer) (*hostWriter, error) {
+	return func(out io.Writer) (*hostWriter, error) {
+		w := csv.NewWriter(out)
+		w.Comma = comma
+
+		if err := w.Write(csvHeader); err != nil {
+			return nil, err
+		}
+
+		return &hostWriter{
+			host: func(res scan.Results) error {
+				if err := writeCSVHost(w, res); err != nil {
+					return err
+				}
+
+				w.Flush()
+				return w.Error()
+			},
+			finish: func(r scanReport) error {
+				if err := writeFindingsCSV(w, r.Results); err != nil {
+					return err
+				}
+
+				w.Flush()
+				return w.Error()
+			},
+		}, nil
+	}
+}
+
+// writeCSVHost writes one row per scanned port of a host. Hosts not found
+// have a single row with the state "not found".
+func writeCSVHost(w *csv.Writer, res scan.Results) error {
+	if res.NotFound {
+		row := make([]string, len(csvHeader))
+		row[0], row[4] = res.Host, "not found"
+
+		return w.Write(row)
+	}
+
+	for _, p := range res.PortStates {
+		// Results from before the ports had an address use the first one of
+		// the host.
+		ip := p.Address
+		if ip == "" && len(res.Addrs) > 0 {
+			ip = res.Addrs[0]
+		}
+
+		service, product, version := scan.LookupService(p.Port, p.Protocol), "", ""
+		if p.Service != nil {
+			service, product, version = p.Service.Name, p.Service.Product, p.Service.Version
+		}
+
+		row := []string{
+			res.Host,
+			ip,
+			strconv.Itoa(p.Port),
+			p.Protocol,
+			p.State.String(),
+			p.Reason,
+			strconv.FormatFloat(float64(p.Latency)/float64(time.Millisecond), 'f', 3, 64),
+			service,
+			p.Banner,
+			product,
+			version,
+		}
+
+		if p.TLS != nil {
+			row = append(row,
+				p.TLS.Version,
+				p.TLS.Cipher,
+				p.TLS.Subject,
+				p.TLS.Issuer,
+				strconv.Itoa(p.TLS.DaysToExpiry),
+				strconv.FormatBool(p.TLS.Verified),
+			)
+		} else {
+			row = append(row, "", "", "", "", "", "")
+		}
+
+		if p.HTTP != nil {
+			row = append(row,
+				strconv.Itoa(p.HTTP.Status),
+				p.HTTP.Server,
+				p.HTTP.Title,
+				p.HTTP.Location,
+				strings.Join(p.HTTP.SecurityHeaders(), " "),
+			)
+		} else {
+			row = append(row, "", "", "", "", "")
+		}
+
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,645 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+// testReport returns a report with one host found and one not found.
+func testReport() scanReport {
+	start := time.Date(2023, 11, 12, 10, 0, 0, 0, time.UTC)
+
+	return scanReport{
+		scanMetadata: scanMetadata{
+			Scanner: "pScan",
+			Version: "0.0.1",
+			Start:   start,
+			End:     start.Add(2 * time.Second),
+			Elapsed: 2 * time.Second,
+			Ports:   []int{22, 80},
+		},
+		Results: []scan.Results{
+			{
+				Host:  "host1",
+				Addrs: []string{"10.0.0.1"},
+				PortStates: []scan.PortState{
+					{Port: 22, Protocol: scan.ProtocolTCP, State: scan.StateOpen, Reason: scan.ReasonSynAck, Latency: time.Millisecond, Banner: "SSH-2.0-OpenSSH_9.3",
+						Service: &scan.Service{Name: "ssh", Product: "OpenSSH", Version: "9.3", Probe: "ssh"}},
+					{Port: 80, Protocol: scan.ProtocolTCP, State: scan.StateFiltered, Reason: scan.ReasonNoResponse, Latency: time.Second},
+				},
+			},
+			{Host: "host2", NotFound: true},
+		},
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var out bytes.Buffer
+
+	if err := writeReport(&out, testReport(), outputOptions{format: "json"}); err != nil {
+		t.Fatalf("Expected no error, got: %q\n", err)
+	}
+
+	var doc struct {
+		Scanner string `json:"scanner"`
+		Ports   []int  `json:"ports"`
+		Hosts   []struct {
+			Host     string `json:"host"`
+			NotFound bool   `json:"not_found"`
+			Ports    []struct {
+				Port      int    `json:"port"`
+				State     string `json:"state"`
+				Reason    string `json:"reason"`
+				LatencyNS int64  `json:"latency_ns"`
+			} `json:"ports"`
+		} `json:"hosts"`
+	}
+
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected valid JSON, got: %q\n", err)
+	}
+
+	if doc.Scanner != "pScan" || len(doc.Ports) != 2 || len(doc.Hosts) != 2 {
+		t.Fatalf("Unexpected document: %s\n", out.String())
+	}
+
+	p := doc.Hosts[0].Ports[1]
+	if p.Port != 80 || p.State != "filtered" || p.Reason != "no-response" || p.LatencyNS != int64(time.Second) {
+		t.Errorf("Unexpected port: %+v\n", p)
+	}
+
+	if !doc.Hosts[1].NotFound {
+		t.Errorf("Expected host %q to NOT be found\n", doc.Hosts[1].Host)
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	var out bytes.Buffer
+
+	if err := writeReport(&out, testReport(), outputOptions{format: "ndjson"}); err != nil {
+		t.Fatalf("Expected no error, got: %q\n", err)
+	}
+
+	expected := []string{
+		`{"type":"port","host":"host1","port":22,"protocol":"tcp","state":"open","reason":"syn-ack","latency_ns":1000000,"banner":"SSH-2.0-OpenSSH_9.3",` +
+			`"service":{"name":"ssh","product":"OpenSSH","version":"9.3","probe":"ssh"}}`,
+		`{"type":"port","host":"host1","port":80,"protocol":"tcp","state":"filtered","reason":"no-response","latency_ns":1000000000}`,
+		`{"type":"host","host":"host1"}`,
+		`{"type":"host","host":"host2","not_found":true}`,
+		`{"type":"scan","scan":{"scanner":"pScan","version":"0.0.1","start":"2023-11-12T10:00:00Z","end":"2023-11-12T10:00:02Z","elapsed_ns":2000000000,"ports":[22,80],"interrupted":false,` +
+			`"summary":{"hosts":2,"up":1,"not_found":1,"open":1,"closed":0,"filtered":1,"unreachable":0,"open_filtered":0}}}`,
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d instead:\n%s", len(expected), len(lines), out.String())
+	}
+
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("Expected line %d: %s, got: %s instead\n", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	testCases := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: "csv",
+			expected: "host,ip,port,protocol,state,reason,latency_ms,service,banner,product,version,tls_version,tls_cipher,cert_subject,cert_issuer,cert_days_left,cert_verified,http_status,http_server,http_title,http_location,http_security_headers\n" +
+				"\"web,\"\"1\"\"\",10.0.0.1,22,tcp,open,syn-ack,1.000,ssh,SSH-2.0-OpenSSH_9.3,OpenSSH,9.3,,,,,,,,,,,\n" +
+				"\"web,\"\"1\"\"\",10.0.0.1,80,tcp,filtered,no-response,1000.000,http,,,,,,,,,,,,,,\n" +
+				"host2,,,,not found,,,,,,,,,,,,,,,,,\n",
+		},
+		{
+			format: "tsv",
+			expected: "host\tip\tport\tprotocol\tstate\treason\tlatency_ms\tservice\tbanner\tproduct\tversion\ttls_version\ttls_cipher\tcert_subject\tcert_issuer\tcert_days_left\tcert_verified\thttp_status\thttp_server\thttp_title\thttp_location\thttp_security_headers\n" +
+				"\"web,\"\"1\"\"\"\t10.0.0.1\t22\ttcp\topen\tsyn-ack\t1.000\tssh\tSSH-2.0-OpenSSH_9.3\tOpenSSH\t9.3\t\t\t\t\t\t\t\t\t\t\t\n" +
+				"\"web,\"\"1\"\"\"\t10.0.0.1\t80\ttcp\tfiltered\tno-response\t1000.000\thttp\t\t\t\t\t\t\t\t\t\t\t\t\t\t\n" +
+				"host2\t\t\t\tnot found\t\t\t\t\t\t\t\t\t\t\t\t\t\t\t\t\t\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			r := testReport()
+
+			// Hostnames with commas or quotes must be escaped.
+			r.Results[0].Host = `web,"1"`
+
+			var out bytes.Buffer
+
+			if err := writeReport(&out, r, outputOptions{format: tc.format}); err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			if out.String() != tc.expected {
+				t.Errorf("Expected output: %q, got: %q instead\n", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	var out bytes.Buffer
+
+	if err := writeReport(&out, testReport(), outputOptions{format: "yaml"}); err == nil {
+		t.Fatal("Expected error for unknown format, got nil")
+	}
+}
+
+func TestWriteTemplate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "OpenPorts",
+			template: `{{range .}}{{.Host}}:{{range openPorts .}} {{.Port}}/{{serviceName .Port}}{{end}}{{"\n"}}{{end}}`,
+			expected: "host1: 22/ssh\nhost2:\n",
+		},
+		{
+			name:     "Join",
+			template: `{{range .}}{{if not .NotFound}}{{join ", " .Addrs}}{{end}}{{end}}`,
+			expected: "10.0.0.1",
+		},
+		{
+			name:     "Color",
+			template: `{{range .}}{{if .NotFound}}{{color "red" .Host}}{{end}}{{end}}`,
+			expected: "\033[31mhost2\033[0m",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := parseTemplate(tc.template)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			var out bytes.Buffer
+
+			if err := writeReport(&out, testReport(), outputOptions{template: tmpl}); err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			if out.String() != tc.expected {
+				t.Errorf("Expected output: %q, got: %q instead\n", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestWriteTemplateError(t *testing.T) {
+	tmpl, err := parseTemplate(`{{range .}}{{color "pink" .Host}}{{end}}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %q\n", err)
+	}
+
+	var out bytes.Buffer
+
+	if err := writeReport(&out, testReport(), outputOptions{template: tmpl}); err == nil {
+		t.Error("Expected error for unknown color, got nil")
+	}
+}
+
+func TestWriteFiltered(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     outputOptions
+		expected string
+	}{
+		{
+			name: "OpenOnly",
+			opts: outputOptions{states: map[scan.State]bool{scan.StateOpen: true}},
+			expected: "host1:\n\t22: open (syn-ack) [ssh OpenSSH 9.3] | SSH-2.0-OpenSSH_9.3\n\n" +
+				"host2: Host not found\n\n" +
+				"2 hosts scanned (1 up, 1 not found): 1 open, 0 closed, 1 filtered, 0 unreachable ports in 2s\n",
+		},
+		{
+			name: "Filtered",
+			opts: outputOptions{states: map[scan.State]bool{scan.StateFiltered: true, scan.StateClosed: true}},
+			expected: "host1:\n\t80: filtered (no-response)\n\n" +
+				"host2: Host not found\n\n" +
+				"2 hosts scanned (1 up, 1 not found): 1 open, 0 closed, 1 filtered, 0 unreachable ports in 2s\n",
+		},
+		{
+			name: "GroupByPort",
+			opts: outputOptions{format: "text", groupBy: "port", states: map[scan.State]bool{scan.StateOpen: true}},
+			expected: "22/tcp (ssh) open: host1, host3\n" +
+				"3 hosts scanned (2 up, 1 not found): 2 open, 0 closed, 1 filtered, 0 unreachable ports in 2s\n",
+		},
+		{
+			name: "GroupByPortStates",
+			opts: outputOptions{format: "text", groupBy: "port", states: map[scan.State]bool{scan.StateOpen: true, scan.StateFiltered: true}},
+			expected: "22/tcp (ssh) open: host1\n" +
+				"22/tcp (ssh) filtered: host3\n" +
+				"80/tcp (http) filtered: host1\n" +
+				"3 hosts scanned (2 up, 1 not found): 1 open, 0 closed, 2 filtered, 0 unreachable ports in 2s\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := testReport()
+
+			if tc.opts.groupBy == "port" {
+				state := scan.StateOpen
+				if tc.opts.states[scan.StateFiltered] {
+					state = scan.StateFiltered
+				}
+
+				r.Results = append(r.Results, scan.Results{
+					Host:       "host3",
+					PortStates: []scan.PortState{{Port: 22, Protocol: scan.ProtocolTCP, State: state}},
+				})
+			}
+
+			var out bytes.Buffer
+
+			if err := writeReport(&out, r, tc.opts); err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			if out.String() != tc.expected {
+				t.Errorf("Expected output: %q, got: %q instead\n", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestFilterResults(t *testing.T) {
+	results := []scan.Results{
+		{Host: "host1", PortStates: []scan.PortState{{Port: 22, State: scan.StateOpen}, {Port: 80, State: scan.StateClosed}}},
+		{Host: "host2", NotFound: true},
+		{Host: "host3", PortStates: []scan.PortState{{Port: 22, State: scan.StateClosed}}},
+	}
+
+	filtered := filterResults(results, map[scan.State]bool{scan.StateOpen: true})
+
+	expected := []scan.Results{
+		{Host: "host1", PortStates: []scan.PortState{{Port: 22, State: scan.StateOpen}}},
+		{Host: "host2", NotFound: true},
+	}
+
+	if !reflect.DeepEqual(filtered, expected) {
+		t.Errorf("Expected results %+v, got %+v instead\n", expected, filtered)
+	}
+}
+
+func TestWriteTLS(t *testing.T) {
+	r := testReport()
+	r.Results[0].PortStates[0].TLS = &scan.TLSInfo{
+		Version:       "TLS 1.3",
+		Cipher:        "TLS_AES_128_GCM_SHA256",
+		ALPN:          "h2",
+		Subject:       "CN=host1",
+		SANs:          []string{"host1", "10.0.0.1"},
+		Issuer:        "CN=Test CA",
+		NotBefore:     time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		DaysToExpiry:  -3,
+		HostnameMatch: true,
+		VerifyError:   "x509: certificate has expired or is not yet valid",
+	}
+
+	testCases := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format:   "text",
+			expected: "\t    TLS 1.3 TLS_AES_128_GCM_SHA256, alpn h2 | CN=host1, expired 3 days ago, not verified\n",
+		},
+		{
+			format:   "csv",
+			expected: ",TLS 1.3,TLS_AES_128_GCM_SHA256,CN=host1,CN=Test CA,-3,false,,,,,\n",
+		},
+		{
+			format:   "json",
+			expected: `"tls": {`,
+		},
+		{
+			format: "nmap-xml",
+			expected: `<script id="ssl-cert" output="Subject: CN=host1&#xA;Subject Alternative Name: DNS:host1, IP Address:10.0.0.1&#xA;` +
+				`Issuer: CN=Test CA&#xA;Not valid before: 2023-01-01T00:00:00&#xA;Not valid after:  2024-01-01T00:00:00"></script>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.format, func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := writeReport(&out, r, outputOptions{format: tc.format}); err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			if !strings.Contains(out.String(), tc.expected) {
+				t.Errorf("Expected output to contain %q, got:\n%s", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestWriteHTTP(t *testing.T) {
+	r := testReport()
+	r.Results[0].PortStates[1].HTTP = &scan.HTTPInfo{
+		URL:           "http://host1:80/",
+		Method:        "GET",
+		Status:        301,
+		Server:        "nginx",
+		Location:      "https://host1/",
+		XFrameOptions: "DENY",
+	}
+
+	testCases := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format:   "text",
+			expected: "\t    GET http://host1:80/ 301, server nginx, location https://host1/, security headers: X-Frame-Options\n",
+		},
+		{
+			format:   "csv",
+			expected: ",tcp,filtered,no-response,1000.000,http,,,,,,,,,,301,nginx,,https://host1/,X-Frame-Options\n",
+		},
+		{
+			format:   "json",
+			expected: `"http": {`,
+		},
+		{
+			format: "nmap-xml",
+			expected: `<script id="http-title" output="Did not follow redirect to https://host1/"></script>` + "\n" +
+				`        <script id="http-server-header" output="nginx"></script>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.format, func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := writeReport(&out, r, outputOptions{format: tc.format}); err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			if !strings.Contains(out.String(), tc.expected) {
+				t.Errorf("Expected output to contain %q, got:\n%s", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestWriteUDP(t *testing.T) {
+	r := testReport()
+	r.UDPPorts = []int{53}
+	r.Results[0].PortStates = append(r.Results[0].PortStates, scan.PortState{
+		Port: 53, Protocol: scan.ProtocolUDP, State: scan.StateOpenFiltered, Reason: scan.ReasonNoResponse, Latency: time.Second,
+	})
+
+	testCases := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format:   "text",
+			expected: "\t53/udp: open|filtered (no-response)\n",
+		},
+		{
+			format:   "text",
+			expected: "1 filtered, 0 unreachable, 1 open|filtered ports in 2s\n",
+		},
+		{
+			format:   "csv",
+			expected: "10.0.0.1,53,udp,open|filtered,no-response,1000.000,domain,,,,,,,,,,,,,,\n",
+		},
+		{
+			format:   "ndjson",
+			expected: `"port":53,"protocol":"udp","state":"open|filtered"`,
+		},
+		{
+			format:   "nmap-xml",
+			expected: `<scaninfo type="udp" protocol="udp" numservices="1" services="53"></scaninfo>`,
+		},
+		{
+			format:   "nmap-xml",
+			expected: `<port protocol="udp" portid="53">`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.format, func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := writeReport(&out, r, outputOptions{format: tc.format}); err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			if !strings.Contains(out.String(), tc.expected) {
+				t.Errorf("Expected output to contain %q, got:\n%s", tc.expected, out.String())
+			}
+		})
+	}
+
+	t.Run("GroupByPort", func(t *testing.T) {
+		var out bytes.Buffer
+
+		if err := writeReport(&out, r, outputOptions{format: "text", groupBy: "port"}); err != nil {
+			t.Fatalf("Expected no error, got: %q\n", err)
+		}
+
+		expected := "22/tcp (ssh) open: host1\n80/tcp (http) filtered: host1\n53/udp (domain) open|filtered: host1\n"
+		if !strings.HasPrefix(out.String(), expected) {
+			t.Errorf("Expected output to start with %q, got:\n%s", expected, out.String())
+		}
+	})
+}
+
+func TestWriteAddresses(t *testing.T) {
+	r := testReport()
+	r.Results[0].Addrs = []string{"10.0.0.1", "2001:db8::1"}
+
+	ports := r.Results[0].PortStates
+	for i := range ports {
+		ports[i].Address = "10.0.0.1"
+	}
+
+	v6 := append([]scan.PortState(nil), ports...)
+	for i := range v6 {
+		v6[i].Address = "2001:db8::1"
+	}
+
+	r.Results[0].PortStates = append(ports, v6...)
+
+	testCases := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format:   "text",
+			expected: "\t10.0.0.1:22: open (syn-ack) [ssh OpenSSH 9.3] | SSH-2.0-OpenSSH_9.3\n",
+		},
+		{
+			format:   "text",
+			expected: "\t[2001:db8::1]:80: filtered (no-response)\n",
+		},
+		{
+			format:   "csv",
+			expected: "host1,2001:db8::1,80,tcp,filtered,",
+		},
+		{
+			format:   "ndjson",
+			expected: `"port":22,"protocol":"tcp","address":"2001:db8::1"`,
+		},
+		{
+			format:   "nmap-xml",
+			expected: `<address addr="2001:db8::1" addrtype="ipv6"></address>`,
+		},
+		{
+			format:   "nmap-xml",
+			expected: `<hosts up="2" down="1" total="3"></hosts>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.format, func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := writeReport(&out, r, outputOptions{format: tc.format}); err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			if !strings.Contains(out.String(), tc.expected) {
+				t.Errorf("Expected output to contain %q, got:\n%s", tc.expected, out.String())
+			}
+		})
+	}
+
+	t.Run("GroupByPort", func(t *testing.T) {
+		var out bytes.Buffer
+
+		if err := writeReport(&out, r, outputOptions{format: "text", groupBy: "port"}); err != nil {
+			t.Fatalf("Expected no error, got: %q\n", err)
+		}
+
+		expected := "22/tcp (ssh) open: host1 (10.0.0.1), host1 (2001:db8::1)\n"
+		if !strings.HasPrefix(out.String(), expected) {
+			t.Errorf("Expected output to start with %q, got:\n%s", expected, out.String())
+		}
+	})
+}
+
+func TestWriteTechnique(t *testing.T) {
+	r := testReport()
+	r.Technique = scan.TechniqueSYN
+
+	testCases := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format:   "json",
+			expected: `"technique": "syn",`,
+		},
+		{
+			format:   "nmap-xml",
+			expected: `args="pScan scan --technique syn --ports 22,80"`,
+		},
+		{
+			format:   "nmap-xml",
+			expected: `<scaninfo type="syn" protocol="tcp"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.format, func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := writeReport(&out, r, outputOptions{format: tc.format}); err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			if !strings.Contains(out.String(), tc.expected) {
+				t.Errorf("Expected output to contain %q, got:\n%s", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+// Test that writing the report one host at a time, as the scan command
+// does, gives the same output as writing it at once
+func TestReportWriter(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts outputOptions
+	}{
+		{"Text", outputOptions{format: "text"}},
+		{"NDJSON", outputOptions{format: "ndjson"}},
+		{"CSV", outputOptions{format: "csv"}},
+		{"TSV", outputOptions{format: "tsv"}},
+		{"JSON", outputOptions{format: "json"}},
+		{"Filtered", outputOptions{format: "text", states: map[scan.State]bool{scan.StateOpen: true}}},
+		{"GroupByPort", outputOptions{format: "text", groupBy: "port", states: map[scan.State]bool{scan.StateOpen: true}}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			var expected, out bytes.Buffer
+
+			if err := writeReport(&expected, testReport(), tc.opts); err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			w, err := newReportWriter(&out, tc.opts)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			r := testReport()
+			streamed := w.hosts != nil
+
+			for _, res := range r.Results {
+				if err := w.host(res); err != nil {
+					t.Fatalf("Expected no error, got: %q\n", err)
+				}
+			}
+
+			// Hosts are written before the end of the scan, unless the output
+			// needs the whole report.
+			if streamed != (out.Len() > 0) {
+				t.Errorf("Expected output before the end only when streamed, got %q\n", out.String())
+			}
+
+			if err := w.finish(r); err != nil {
+				t.Fatalf("Expected no error, got: %q\n", err)
+			}
+
+			if out.String() != expected.String() {
+				t.Errorf("Expected output:\n%s\ngot:\n%s\n", expected.String(), out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright © 2023 Still Learning LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+// statusOptions defines what is shown on stderr while the scan runs.
+type statusOptions struct {
+	// out receives the status. Nothing is shown when it is nil.
+	out io.Writer
+	// progress shows the progress of the scan.
+	progress bool
+	// verbose shows the settings of the scan, and the timeout chosen for
+	// each host by the adaptive timing.
+	verbose bool
+}
+
+const (
+	// terminalRedraw is the minimum time between two redraws of the
+	// progress on a terminal.
+	terminalRedraw = 200 * time.Millisecond
+	// logInterval is the minimum time between two progress lines when
+	// stderr is not a terminal.
+	logInterval = 10 * time.Second
+)
+
+// progress shows how far a scan is on stderr, from the events of the scan.
+// On a terminal, it is a single line redrawn in place. Otherwise, it is a
+// log line every logInterval. A nil progress shows nothing.
+type progress struct {
+	out      io.Writer
+	terminal bool
+	start    time.Time
+	// last is when the progress was last shown, and drawn is true while it
+	// is shown on the terminal.
+	last  time.Time
+	drawn bool
+
+	// portsPerHost is the number of ports scanned on each address, and
+	// firstAddress is true when only the first address of each host is.
+	portsPerHost uint64
+	firstAddress bool
+	hosts        uint64
+	hostsDone    uint64
+	ports        uint64
+	portsDone    uint64
+	open         uint64
+}
+
+// newProgress returns the progress of a scan of hosts, with portsPerHost
+// ports on each of their addresses, or only on the first one when
+// firstAddress is true, written to out. The total of ports starts at one
+// address per host, and grows as hosts resolve to more.
+func newProgress(out io.Writer, hosts uint64, portsPerHost int, firstAddress bool) *progress {
+	now := clock()
+
+	p := &progress{
+		out:          out,
+		terminal:     isTerminal(out),
+		start:        now,
+		last:         now,
+		portsPerHost: uint64(portsPerHost),
+		firstAddress: firstAddress,
+		hosts:        hosts,
+		ports:        math.MaxUint64,
+	}
+
+	if p.portsPerHost == 0 || hosts <= math.MaxUint64/p.portsPerHost {
+		p.ports = hosts * p.portsPerHost
+	}
+
+	return p
+}
+
+// isTerminal reports whether w is a terminal, on which the progress can be
+// redrawn in place.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// event counts the scan event, and shows the progress when it is time to.
+func (p *progress) event(ev scan.Event) {
+	if p == nil {
+		return
+	}
+
+	switch ev.Type {
+	case scan.EventHostResolved:
+		if !p.firstAddress && len(ev.Addrs) > 1 {
+			p.addPorts(uint64(len(ev.Addrs)-1) * p.portsPerHost)
+		}
+
+		return
+	case scan.EventPortResult:
+		p.portsDone++
+
+		if ev.Port.State == scan.StateOpen {
+			p.open++
+		}
+	case scan.EventHostDone:
+		p.hostsDone++
+
+		// Hosts not found have no ports to scan.
+		if ev.Results.NotFound && p.ports >= p.portsPerHost {
+			p.ports -= p.portsPerHost
+		}
+	default:
+		return
+	}
+
+	now := clock()
+
+	switch {
+	case p.terminal && (!p.drawn || now.Sub(p.last) >= terminalRedraw):
+		fmt.Fprintf(p.out, "\r\033[K%s", p.line(now))
+		p.drawn = true
+	case !p.terminal && now.Sub(p.last) >= logInterval:
+		fmt.Fprintln(p.out, p.line(now))
+	default:
+		return
+	}
+
+	p.last = now
+}
+
+// addPorts adds n ports to the total, which saturates rather than wraps.
+func (p *progress) addPorts(n uint64) {
+	if p.ports > math.MaxUint64-n {
+		p.ports = math.MaxUint64
+		return
+	}
+
+	p.ports += n
+}
+
+// line describes the progress at now, such as "Scanning: 12/256 hosts,
+// 36/768 ports (4.7%), 5 open, 120 ports/s, ETA 6s".
+func (p *progress) line(now time.Time) string {
+	percent := 100.0
+	if p.ports > 0 {
+		percent = float64(p.portsDone) * 100 / float64(p.ports)
+	}
+
+	line := fmt.Sprintf("Scanning: %d/%d hosts, %d/%d ports (%.1f%%), %d open",
+		p.hostsDone, p.hosts, p.portsDone, p.ports, percent, p.open)
+
+	elapsed := now.Sub(p.start)
+	if elapsed <= 0 || p.portsDone == 0 {
+		return line
+	}
+
+	rate := float64(p.portsDone) / elapsed.Seconds()
+	line += fmt.Sprintf(", %.0f ports/s", rate)
+
+	if p.ports > p.portsDone {
+		eta := time.Duration(float64(p.ports-p.portsDone) / rate * float64(time.Second))
+		line += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+
+	return line
+}
+
+// clear erases the progress from the terminal, before writing the results
+// to it. The next event draws it again.
+func (p *progress) clear() {
+	if p == nil || !p.drawn {
+		return
+	}
+
+	fmt.Fprint(p.out, "\r\033[K")
+	p.drawn = false
+}
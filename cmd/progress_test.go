@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+func TestProgress(t *testing.T) {
+	now := time.Date(2023, 11, 12, 10, 0, 0, 0, time.UTC)
+	clock = func() time.Time { return now }
+
+	t.Cleanup(func() { clock = time.Now })
+
+	var out bytes.Buffer
+
+	p := newProgress(&out, 3, 2, false)
+
+	port := func(state scan.State) scan.Event {
+		return scan.Event{Type: scan.EventPortResult, Port: &scan.PortState{State: state}}
+	}
+
+	p.event(scan.Event{Type: scan.EventHostDone, Results: &scan.Results{NotFound: true}})
+	// Hosts with several addresses have their ports scanned on each one.
+	p.event(scan.Event{Type: scan.EventHostResolved, Addrs: []string{"192.0.2.1", "2001:db8::1"}})
+	p.event(port(scan.StateOpen))
+
+	// Nothing is logged before the interval, when stderr is not a terminal.
+	if out.Len() != 0 {
+		t.Fatalf("Expected no progress yet, got %q\n", out.String())
+	}
+
+	now = now.Add(logInterval)
+	p.event(port(scan.StateClosed))
+
+	expected := "Scanning: 1/3 hosts, 2/6 ports (33.3%), 1 open, 0 ports/s, ETA 20s\n"
+
+	if out.String() != expected {
+		t.Errorf("Expected progress %q, got %q instead\n", expected, out.String())
+	}
+
+	// Clearing only applies to terminals.
+	p.clear()
+
+	if out.String() != expected {
+		t.Errorf("Expected clear to write nothing, got %q\n", out.String())
+	}
+
+	first := newProgress(&out, 3, 2, true)
+	first.event(scan.Event{Type: scan.EventHostResolved, Addrs: []string{"192.0.2.1", "2001:db8::1"}})
+
+	if first.ports != 6 {
+		t.Errorf("Expected 6 ports when scanning the first addresses, got %d instead\n", first.ports)
+	}
+
+	var nilProgress *progress
+
+	nilProgress.event(port(scan.StateOpen))
+	nilProgress.clear()
+}
@@ -17,43 +17,651 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
 
 	"github.com/Dbaker1298/pScan/scan"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
+// clock returns the current time. Tests replace it to get a stable elapsed
+// time in the output.
+var clock = time.Now //nolint:gochecknoglobals
+
 // scanCmd represents the scan command
 var scanCmd = &cobra.Command{
-	Use:   "scan",
-	Short: "Run a port scan on the hosts list",
+	Use:          "scan",
+	Short:        "Run a port scan on the hosts list",
+	SilenceUsage: true,
+	Long: `Run a port scan on the hosts list.
+
+The --ports flag takes a comma separated list of:
+  22           a single port
+  1-1024       an inclusive range of ports
+  ssh,http     service names, as found in /etc/services
+  top:100      the 100 most common ports
+  !25          any of the above prefixed with ! to exclude it
+  U:53,161     UDP ports: T: and U: select TCP or UDP for the following
+               items, such as in T:22,80,U:53,snmp; ! goes before or
+               after them, as in !U:53 or U:!53, without changing the
+               protocol of the following items
+
+Ports are TCP unless prefixed with U:, or --udp is set. UDP ports get a
+payload crafted for DNS, NTP, SNMP, syslog and WireGuard on their
+well-known ports, and an empty datagram otherwise. They are open when they
+answer, closed when the host reports them unreachable, and open|filtered
+when nothing comes back, which is common for silent services.
+
+TCP ports are scanned by connecting to them, unless --technique syn is set.
+SYN scans only send the first packet of the TCP handshake, so they never
+show up in the logs of the services, and tell open ports from a SYN/ACK
+reply and closed ones from a reset. They need Linux and the CAP_NET_RAW
+capability, usually as root, and only reach IPv4 addresses. Otherwise,
+pScan warns and falls back to connect scans.
+
+Hosts are scanned on every address they resolve to, IPv4 and IPv6, and
+each result shows the address it was scanned on. Use -4 or -6 to scan only
+the IPv4 or IPv6 addresses, and --first-address to scan only the first
+address of each host. IPv6 link-local addresses take the zone of their
+interface, as in fe80::1%eth0.
+
+TCP ports that do not answer within --timeout are filtered. Lost packets
+can make open ports look filtered too, and --retries scans them again.
+With --adaptive, pScan measures the round-trip time to each host from its
+ports that answer, open or closed, and lowers the timeout of the host to
+fit, the way TCP does, down to 100ms. Fast networks are then scanned
+quicker, while slow links keep the whole --timeout. Use --verbose to see
+the timeout chosen for each host.
+
+To keep the scan gentle on production networks and below the thresholds of
+intrusion detection systems, --rate caps the ports scanned per second
+across all hosts, and --host-rate and --max-per-host cap the ports scanned
+per second and at the same time on each host. Networks can get their own
+limits, shared by all their hosts, in the config file:
+
+  limits:
+    - network: 10.20.0.0/16
+      rate: 50
+      burst: 5
+      max-concurrent: 10
+
+With --detect-services, open ports are probed with the HTTP, TLS, SSH,
+SMTP, Redis, PostgreSQL, MySQL and MongoDB handshakes to identify the
+service, product and version actually running, instead of guessing from
+the port number. The probes for the port go first, along with the probes
+matching the banner when --banners is set, and --identify-timeout bounds
+the identification of each port.
+
+With --tls, pScan performs a TLS handshake on open ports, using STARTTLS
+for SMTP, IMAP, POP3, FTP and PostgreSQL, and reports the negotiated
+version, cipher and ALPN protocol along with the certificate subject,
+names, issuer, expiry and whether its chain is trusted by the system.
+
+With --http, pScan requests the root page of the web servers on open ports
+and reports the status code, Server header, page title, redirect location
+and security headers: Strict-Transport-Security, Content-Security-Policy
+and X-Frame-Options. Web servers are found by port number, or on any port
+along with --detect-services. Use --http-method HEAD to skip the page
+bodies, and their titles.
+
+With --check tls, pScan also reports findings on TLS ports: certificates
+expired, expiring within --expiry-days, self-signed, untrusted or not
+valid for the host, and servers accepting TLS 1.0, TLS 1.1 or weak cipher
+suites. Findings have a severity, and --fail-on makes pScan exit with an
+error when any finding reaches it, for example to fail a CI job:
+
+  pScan scan --ports https --check tls --fail-on high
+
+The --output flag selects the results format. The json and ndjson formats
+are meant for other programs, see docs/output-formats.md for their schema.
+The text, ndjson, csv and tsv formats show each host as soon as it is
+scanned, in the order of the hosts list.
+
+While scanning, pScan shows the hosts and ports done, the open ports found,
+the rate and the estimated time left on stderr: a single line updated in
+place on a terminal, or a line every 10 seconds otherwise. Use
+--no-progress to hide it.
+
+For custom layouts, --template and --template-file render the list of
+results with a Go text/template, for example:
+
+  pScan scan --template '{{range .}}{{.Host}}:{{range openPorts .}} {{.Port}}/{{serviceName .Port}}{{end}}{{"\n"}}{{end}}'
+
+Besides the standard template functions, templates can use:
+  openPorts RESULT       the open ports of a host
+  serviceName PORT       the service usually found on a port
+  join SEP LIST          the elements of LIST separated by SEP
+  color NAME VALUE       VALUE in color: bold, red, green, yellow, blue,
+                         magenta or cyan`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		hostsFile, err := cmd.Flags().GetString("hosts-file")
 		if err != nil {
 			return err
 		}
 
-		ports, err := cmd.Flags().GetIntSlice("ports")
+		portSpec, err := cmd.Flags().GetString("ports")
+		if err != nil {
+			return err
+		}
+
+		udp, err := cmd.Flags().GetBool("udp")
+		if err != nil {
+			return err
+		}
+
+		proto := scan.ProtocolTCP
+		if udp {
+			proto = scan.ProtocolUDP
+		}
+
+		ports, udpPorts, err := scan.ParsePortSpec(portSpec, proto)
+		if err != nil {
+			return err
+		}
+
+		udpTimeout, err := cmd.Flags().GetDuration("udp-timeout")
+		if err != nil {
+			return err
+		}
+
+		technique, err := cmd.Flags().GetString("technique")
+		if err != nil {
+			return err
+		}
+
+		switch technique {
+		case scan.TechniqueConnect:
+		case scan.TechniqueSYN:
+			if err := scan.CheckSYN(); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v, falling back to a connect scan\n", err)
+				technique = scan.TechniqueConnect
+			}
+		default:
+			return fmt.Errorf("unknown --technique %q, use connect or syn", technique)
+		}
+
+		concurrency, err := cmd.Flags().GetInt("concurrency")
 		if err != nil {
 			return err
 		}
 
-		return scanAction(os.Stdout, hostsFile, ports)
+		rate, err := cmd.Flags().GetInt("rate")
+		if err != nil {
+			return err
+		}
+
+		hostRate, err := cmd.Flags().GetInt("host-rate")
+		if err != nil {
+			return err
+		}
+
+		maxPerHost, err := cmd.Flags().GetInt("max-per-host")
+		if err != nil {
+			return err
+		}
+
+		limits, err := networkLimits(viper.GetViper())
+		if err != nil {
+			return err
+		}
+
+		maxScanTime, err := cmd.Flags().GetDuration("max-scan-time")
+		if err != nil {
+			return err
+		}
+
+		noProgress, err := cmd.Flags().GetBool("no-progress")
+		if err != nil {
+			return err
+		}
+
+		verbose, err := cmd.Flags().GetBool("verbose")
+		if err != nil {
+			return err
+		}
+
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			return err
+		}
+
+		retries, err := cmd.Flags().GetInt("retries")
+		if err != nil {
+			return err
+		}
+
+		adaptive, err := cmd.Flags().GetBool("adaptive")
+		if err != nil {
+			return err
+		}
+
+		format, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+
+		banners, err := cmd.Flags().GetBool("banners")
+		if err != nil {
+			return err
+		}
+
+		bannerSize, err := cmd.Flags().GetInt("banner-size")
+		if err != nil {
+			return err
+		}
+
+		bannerTimeout, err := cmd.Flags().GetDuration("banner-timeout")
+		if err != nil {
+			return err
+		}
+
+		detectServices, err := cmd.Flags().GetBool("detect-services")
+		if err != nil {
+			return err
+		}
+
+		probeTimeout, err := cmd.Flags().GetDuration("probe-timeout")
+		if err != nil {
+			return err
+		}
+
+		identifyTimeout, err := cmd.Flags().GetDuration("identify-timeout")
+		if err != nil {
+			return err
+		}
+
+		inspectTLS, err := cmd.Flags().GetBool("tls")
+		if err != nil {
+			return err
+		}
+
+		tlsTimeout, err := cmd.Flags().GetDuration("tls-timeout")
+		if err != nil {
+			return err
+		}
+
+		checks, err := cmd.Flags().GetStringSlice("check")
+		if err != nil {
+			return err
+		}
+
+		expiryDays, err := cmd.Flags().GetInt("expiry-days")
+		if err != nil {
+			return err
+		}
+
+		failOn, err := cmd.Flags().GetString("fail-on")
+		if err != nil {
+			return err
+		}
+
+		enumerateHTTP, err := cmd.Flags().GetBool("http")
+		if err != nil {
+			return err
+		}
+
+		httpMethod, err := cmd.Flags().GetString("http-method")
+		if err != nil {
+			return err
+		}
+
+		httpTimeout, err := cmd.Flags().GetDuration("http-timeout")
+		if err != nil {
+			return err
+		}
+
+		ipv4, err := cmd.Flags().GetBool("ipv4")
+		if err != nil {
+			return err
+		}
+
+		ipv6, err := cmd.Flags().GetBool("ipv6")
+		if err != nil {
+			return err
+		}
+
+		firstAddress, err := cmd.Flags().GetBool("first-address")
+		if err != nil {
+			return err
+		}
+
+		ipVersion := 0
+		switch {
+		case ipv4 && ipv6:
+			return errors.New("-4 and -6 cannot be used together")
+		case ipv4:
+			ipVersion = 4
+		case ipv6:
+			ipVersion = 6
+		}
+
+		httpMethod = strings.ToUpper(httpMethod)
+		if httpMethod != http.MethodGet && httpMethod != http.MethodHead {
+			return fmt.Errorf("unknown --http-method %q, use GET or HEAD", httpMethod)
+		}
+
+		cfg := scan.Config{
+			Technique:       technique,
+			IPVersion:       ipVersion,
+			FirstAddress:    firstAddress,
+			UDPPorts:        udpPorts,
+			UDPTimeout:      udpTimeout,
+			Timeout:         timeout,
+			Adaptive:        adaptive,
+			Retries:         retries,
+			Concurrency:     concurrency,
+			Rate:            rate,
+			HostRate:        hostRate,
+			MaxPerHost:      maxPerHost,
+			NetworkLimits:   limits,
+			Banners:         banners,
+			BannerSize:      bannerSize,
+			BannerTimeout:   bannerTimeout,
+			ProbeTimeout:    probeTimeout,
+			IdentifyTimeout: identifyTimeout,
+			TLS:             inspectTLS,
+			TLSTimeout:      tlsTimeout,
+			CertExpiryDays:  expiryDays,
+			HTTP:            enumerateHTTP,
+			HTTPMethod:      httpMethod,
+			HTTPTimeout:     httpTimeout,
+		}
+
+		if detectServices {
+			cfg.Probes = scan.Probes()
+		}
+		if err := parseChecks(checks, &cfg); err != nil {
+			return err
+		}
+
+		opts := outputOptions{format: format}
+
+		if opts.failOn, err = parseFailOn(failOn, cfg); err != nil {
+			return err
+		}
+
+		if err := checkFormat(format); err != nil {
+			return err
+		}
+
+		if opts.template, err = templateFromFlags(cmd); err != nil {
+			return err
+		}
+
+		if err := filtersFromFlags(cmd, &opts); err != nil {
+			return err
+		}
+
+		// Stop the scan on Ctrl-C or SIGTERM, so the partial results
+		// can still be printed.
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if maxScanTime > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, maxScanTime)
+			defer cancel()
+		}
+
+		status := statusOptions{out: cmd.ErrOrStderr(), progress: !noProgress, verbose: verbose}
+
+		return scanAction(ctx, os.Stdout, status, hostsFile, ports, cfg, opts)
 	},
 }
 
-func scanAction(out io.Writer, hostsFile string, ports []int) error {
+// templateFromFlags parses the output template given by the --template or
+// --template-file flags. It returns nil if neither is set.
+func templateFromFlags(cmd *cobra.Command) (*template.Template, error) {
+	text, err := cmd.Flags().GetString("template")
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := cmd.Flags().GetString("template-file")
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case text != "" && file != "":
+		return nil, errors.New("--template and --template-file cannot be used together")
+	case text == "" && file == "":
+		return nil, nil
+	case cmd.Flags().Changed("output"):
+		return nil, errors.New("--output cannot be used with a template")
+	}
+
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		text = string(b)
+	}
+
+	return parseTemplate(text)
+}
+
+// filtersFromFlags sets the result filters and grouping in opts from the
+// --open-only, --state and --group-by flags.
+func filtersFromFlags(cmd *cobra.Command, opts *outputOptions) error {
+	openOnly, err := cmd.Flags().GetBool("open-only")
+	if err != nil {
+		return err
+	}
+
+	stateNames, err := cmd.Flags().GetStringSlice("state")
+	if err != nil {
+		return err
+	}
+
+	if opts.groupBy, err = cmd.Flags().GetString("group-by"); err != nil {
+		return err
+	}
+
+	if openOnly {
+		stateNames = append(stateNames, scan.StateOpen.String())
+	}
+
+	if opts.states, err = parseStates(stateNames); err != nil {
+		return err
+	}
+
+	switch opts.groupBy {
+	case "host":
+	case "port":
+		if opts.format != "text" || opts.template != nil {
+			return errors.New("--group-by port requires the text output")
+		}
+
+		// Listing every host for each port is only useful for some states,
+		// so show the open ones unless told otherwise.
+		if opts.states == nil {
+			opts.states = map[scan.State]bool{scan.StateOpen: true}
+		}
+	default:
+		return fmt.Errorf("unknown --group-by %q, use host or port", opts.groupBy)
+	}
+
+	return nil
+}
+
+// synFallbacks returns why the TCP ports of the addresses of a host are
+// scanned with connect scans instead of the SYN scan selected by cfg, one
+// error per address.
+func synFallbacks(addrs []string, tcpPorts int, cfg scan.Config) []error {
+	if cfg.Technique != scan.TechniqueSYN || tcpPorts == 0 {
+		return nil
+	}
+
+	if cfg.FirstAddress && len(addrs) > 1 {
+		addrs = addrs[:1]
+	}
+
+	var errs []error
+
+	for _, addr := range addrs {
+		if err := scan.CheckSYNAddr(addr); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// scanAction scans the hosts in hostsFile and writes the report to out,
+// while showing the status selected by status.
+func scanAction(ctx context.Context, out io.Writer, status statusOptions, hostsFile string, ports []int, cfg scan.Config, opts outputOptions) error {
 	hl := &scan.HostsList{}
 
 	if err := hl.Load(hostsFile); err != nil {
 		return err
 	}
 
-	results := scan.Run(hl, ports)
+	var hosts uint64
+	for _, h := range hl.Hosts {
+		hosts += scan.CountHosts(h)
+	}
+
+	portsPerHost := len(ports) + len(cfg.UDPPorts)
+
+	var prog *progress
+
+	if status.out != nil && status.progress {
+		prog = newProgress(status.out, hosts, portsPerHost, cfg.FirstAddress)
+	}
+
+	verbose := status.out != nil && status.verbose
+
+	if verbose {
+		printSettings(status.out, hosts, portsPerHost, cfg)
+	}
+
+	report := scanReport{
+		scanMetadata: scanMetadata{
+			Scanner:   rootCmd.Name(),
+			Version:   rootCmd.Version,
+			Start:     clock(),
+			Technique: cfg.Technique,
+			Ports:     ports,
+			UDPPorts:  cfg.UDPPorts,
+		},
+	}
+
+	w, err := newReportWriter(out, opts)
+	if err != nil {
+		return err
+	}
+
+	// Stop scanning when the output cannot be written anymore.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		results  = []scan.Results{}
+		done     = map[int]scan.Results{}
+		scanErr  error
+		writeErr error
+	)
+
+	// Hosts are written in the order of the hosts list, as soon as they and
+	// all the hosts before them are done.
+	write := func(r scan.Results) {
+		results = append(results, r)
+
+		if verbose && r.Timing != nil {
+			prog.clear()
+			printTiming(status.out, r)
+		}
+
+		if writeErr == nil {
+			prog.clear()
+
+			if writeErr = w.host(r); writeErr != nil {
+				cancel()
+			}
+		}
+	}
+
+	for ev := range scan.NewScanner(scan.WithConfig(cfg)).Stream(ctx, hl.Hosts, ports) {
+		switch ev.Type {
+		case scan.EventHostResolved:
+			for _, err := range synFallbacks(ev.Addrs, len(ports), cfg) {
+				if status.out != nil {
+					prog.clear()
+					fmt.Fprintf(status.out, "Warning: %v, falling back to a connect scan\n", err)
+				}
+			}
+		case scan.EventHostDone:
+			done[ev.Index] = *ev.Results
 
-	return printResults(out, results)
+			for r, ok := done[len(results)]; ok; r, ok = done[len(results)] {
+				delete(done, len(results))
+				write(r)
+			}
+		case scan.EventScanDone:
+			scanErr = ev.Err
+		}
+
+		prog.event(ev)
+	}
+
+	prog.clear()
+
+	// Hosts interrupted before they were looked up leave gaps in the
+	// order, so the hosts after them are only written now.
+	indexes := make([]int, 0, len(done))
+	for i := range done {
+		indexes = append(indexes, i)
+	}
+
+	sort.Ints(indexes)
+
+	for _, i := range indexes {
+		write(done[i])
+	}
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	report.End = clock()
+	report.Elapsed = report.End.Sub(report.Start)
+	report.Interrupted = scanErr != nil
+	report.Results = results
+
+	if err := w.finish(report); err != nil {
+		return err
+	}
+
+	switch {
+	case errors.Is(scanErr, context.DeadlineExceeded):
+		return fmt.Errorf("maximum scan time reached, showing partial results: %w", scanErr)
+	case scanErr != nil:
+		return fmt.Errorf("scan interrupted, showing partial results: %w", scanErr)
+	}
+
+	if opts.failOn != nil {
+		if n := countFindings(results, *opts.failOn); n > 0 {
+			return fmt.Errorf("%d findings with %s severity or higher", n, *opts.failOn)
+		}
+	}
+
+	return nil
 }
 
 func printResults(out io.Writer, results []scan.Results) error {
@@ -70,7 +678,25 @@ func printResults(out io.Writer, results []scan.Results) error {
 		message += fmt.Sprintln()
 
 		for _, p := range r.PortStates {
-			message += fmt.Sprintf("\t%d: %s\n", p.Port, p.Open)
+			message += fmt.Sprintf("\t%s: %s (%s)", portLabel(p), p.State, p.Reason)
+
+			if p.Service != nil {
+				message += fmt.Sprintf(" [%s]", serviceLabel(p.Service))
+			}
+
+			if p.Banner != "" {
+				message += fmt.Sprintf(" | %s", p.Banner)
+			}
+
+			message += fmt.Sprintln()
+
+			if p.TLS != nil {
+				message += fmt.Sprintf("\t    %s\n", tlsLabel(p.TLS))
+			}
+
+			if p.HTTP != nil {
+				message += fmt.Sprintf("\t    %s\n", httpLabel(p.HTTP))
+			}
 		}
 
 		message += fmt.Sprintln()
@@ -80,8 +706,192 @@ func printResults(out io.Writer, results []scan.Results) error {
 	return err
 }
 
+// printSettings writes the settings of the scan to the verbose output,
+// such as "Scanning 2 hosts, 3 ports each, timeout 1s, 0 retries".
+func printSettings(out io.Writer, hosts uint64, portsPerHost int, cfg scan.Config) {
+	timeout := cfg.Timeout
+	if timeout < 1 {
+		timeout = scan.DefaultTimeout
+	}
+
+	adaptive := ""
+	if cfg.Adaptive {
+		adaptive = fmt.Sprintf(", adaptive down to %s", scan.MinAdaptiveTimeout)
+	}
+
+	addrs := ""
+	switch {
+	case cfg.IPVersion != 0 && cfg.FirstAddress:
+		addrs = fmt.Sprintf(" on their first IPv%d address", cfg.IPVersion)
+	case cfg.IPVersion != 0:
+		addrs = fmt.Sprintf(" on their IPv%d addresses", cfg.IPVersion)
+	case cfg.FirstAddress:
+		addrs = " on their first address"
+	}
+
+	fmt.Fprintf(out, "Scanning %d hosts%s, %d ports each, timeout %s%s, %d retries\n",
+		hosts, addrs, portsPerHost, timeout, adaptive, cfg.Retries)
+}
+
+// printTiming writes the timeout chosen for a host by the adaptive timing
+// to the verbose output, such as "host1: timeout 100ms, rtt 21ms ± 4ms
+// from 3 answers".
+func printTiming(out io.Writer, r scan.Results) {
+	t := r.Timing
+
+	if t.Samples == 0 {
+		fmt.Fprintf(out, "%s: timeout %s, no answers to measure the rtt\n", r.Host, t.Timeout)
+		return
+	}
+
+	fmt.Fprintf(out, "%s: timeout %s, rtt %s ± %s from %d answers\n",
+		r.Host, t.Timeout, t.SRTT.Round(time.Microsecond), t.RTTVar.Round(time.Microsecond), t.Samples)
+}
+
+// portLabel returns the port number, followed by /udp for UDP ports, and
+// preceded by the address it was scanned on, such as "[2001:db8::1]:53/udp".
+func portLabel(p scan.PortState) string {
+	label := strconv.Itoa(p.Port)
+	if p.Address != "" {
+		label = net.JoinHostPort(p.Address, label)
+	}
+
+	if p.Protocol == scan.ProtocolUDP {
+		label += "/udp"
+	}
+
+	return label
+}
+
+// hostLabel returns the host followed by the address it was scanned on,
+// such as "example.com (192.0.2.1)", or only the host when the address is
+// unknown or the same.
+func hostLabel(host, addr string) string {
+	if addr == "" || addr == host {
+		return host
+	}
+
+	return fmt.Sprintf("%s (%s)", host, addr)
+}
+
+// serviceLabel returns the name, product and version of svc separated by
+// spaces, such as "ssh OpenSSH 9.3p1".
+func serviceLabel(svc *scan.Service) string {
+	label := svc.Name
+
+	for _, s := range []string{svc.Product, svc.Version} {
+		if s != "" {
+			label += " " + s
+		}
+	}
+
+	return label
+}
+
+// tlsLabel summarizes the TLS session and certificate on a single line,
+// such as "TLS 1.3 TLS_AES_128_GCM_SHA256, alpn h2 | CN=example.com,
+// expires in 89 days, verified".
+func tlsLabel(info *scan.TLSInfo) string {
+	label := fmt.Sprintf("%s %s", info.Version, info.Cipher)
+
+	if info.StartTLS != "" {
+		label += ", starttls " + info.StartTLS
+	}
+
+	if info.ALPN != "" {
+		label += ", alpn " + info.ALPN
+	}
+
+	label += " | " + info.Subject
+
+	if info.DaysToExpiry < 0 {
+		label += fmt.Sprintf(", expired %d days ago", -info.DaysToExpiry)
+	} else {
+		label += fmt.Sprintf(", expires in %d days", info.DaysToExpiry)
+	}
+
+	if info.SelfSigned {
+		label += ", self-signed"
+	}
+
+	if !info.HostnameMatch {
+		label += ", hostname mismatch"
+	}
+
+	if info.Verified {
+		label += ", verified"
+	} else {
+		label += ", not verified"
+	}
+
+	return label
+}
+
+// httpLabel summarizes the response of a web server on a single line, such
+// as `GET http://example.com:80/ 200 "Home", server nginx, security headers:
+// X-Frame-Options`.
+func httpLabel(info *scan.HTTPInfo) string {
+	label := fmt.Sprintf("%s %s %d", info.Method, info.URL, info.Status)
+
+	if info.Title != "" {
+		label += fmt.Sprintf(" %q", info.Title)
+	}
+
+	if info.Server != "" {
+		label += ", server " + info.Server
+	}
+
+	if info.Location != "" {
+		label += ", location " + info.Location
+	}
+
+	if headers := info.SecurityHeaders(); len(headers) > 0 {
+		label += ", security headers: " + strings.Join(headers, ", ")
+	} else {
+		label += ", no security headers"
+	}
+
+	return label
+}
+
 func init() {
 	rootCmd.AddCommand(scanCmd)
 
-	scanCmd.Flags().IntSlice("ports", []int{22, 80, 443}, "Ports to scan")
+	scanCmd.Flags().String("ports", "22,80,443", "Ports to scan, e.g. 22,8000-8100,https,top:100,!25,U:53")
+	scanCmd.Flags().Bool("udp", false, "Scan the --ports without a T: or U: prefix as UDP ports")
+	scanCmd.Flags().Duration("udp-timeout", scan.DefaultUDPTimeout, "How long to wait for an answer from each UDP port")
+	scanCmd.Flags().String("technique", scan.TechniqueConnect, "How to scan TCP ports: connect, or syn for half-open scans")
+	scanCmd.Flags().BoolP("ipv4", "4", false, "Scan only the IPv4 addresses of the hosts")
+	scanCmd.Flags().BoolP("ipv6", "6", false, "Scan only the IPv6 addresses of the hosts")
+	scanCmd.Flags().Bool("first-address", false, "Scan only the first address of each host, instead of all of them")
+	scanCmd.Flags().Duration("timeout", scan.DefaultTimeout, "How long to wait for each TCP port to answer")
+	scanCmd.Flags().Int("retries", 0, "How many more times to scan the ports that did not answer")
+	scanCmd.Flags().Bool("adaptive", false, "Tune the --timeout of each host from its round-trip times")
+	scanCmd.Flags().IntP("concurrency", "c", scan.DefaultConcurrency, "Maximum number of ports to scan at the same time")
+	scanCmd.Flags().Int("rate", 0, "Maximum number of ports to scan per second, across all hosts (0 means no limit)")
+	scanCmd.Flags().Int("host-rate", 0, "Maximum number of ports to scan per second on each host (0 means no limit)")
+	scanCmd.Flags().Int("max-per-host", 0, "Maximum number of ports to scan at the same time on each host (0 means no limit)")
+	scanCmd.Flags().StringP("output", "o", "text", "Output format: "+formatNames())
+	scanCmd.Flags().Bool("banners", false, "Read the banner sent by the server on open ports")
+	scanCmd.Flags().Int("banner-size", scan.DefaultBannerSize, "Maximum number of bytes to read for a banner")
+	scanCmd.Flags().Duration("banner-timeout", scan.DefaultBannerTimeout, "How long to wait for a banner")
+	scanCmd.Flags().Bool("detect-services", false, "Identify the service on open ports by talking its protocol")
+	scanCmd.Flags().Duration("probe-timeout", scan.DefaultProbeTimeout, "How long each service probe can take")
+	scanCmd.Flags().Duration("identify-timeout", scan.DefaultIdentifyTimeout, "How long identifying the service of a port can take, all probes included")
+	scanCmd.Flags().Bool("tls", false, "Inspect the TLS session and certificate on open ports")
+	scanCmd.Flags().Duration("tls-timeout", scan.DefaultTLSTimeout, "How long each TLS handshake can take")
+	scanCmd.Flags().Bool("http", false, "Request the root page of web servers on open ports")
+	scanCmd.Flags().String("http-method", scan.DefaultHTTPMethod, "Method of the --http requests: GET or HEAD")
+	scanCmd.Flags().Duration("http-timeout", scan.DefaultHTTPTimeout, "How long each --http request can take")
+	scanCmd.Flags().StringSlice("check", nil, "Report findings from these checks: tls")
+	scanCmd.Flags().Int("expiry-days", scan.DefaultCertExpiryDays, "Report certificates expiring within this many days")
+	scanCmd.Flags().String("fail-on", "", "Exit with an error if any finding has this severity or higher: info, low, medium, high, critical")
+	scanCmd.Flags().Bool("open-only", false, "Show only open ports")
+	scanCmd.Flags().StringSlice("state", nil, "Show only ports in these states: open, closed, filtered, unreachable, open|filtered")
+	scanCmd.Flags().String("group-by", "host", "Group the text output by host or port")
+	scanCmd.Flags().String("template", "", "Go template to render the results with, instead of --output")
+	scanCmd.Flags().String("template-file", "", "File with a Go template to render the results with")
+	scanCmd.Flags().BoolP("verbose", "v", false, "Show the scan settings and the adaptive timeouts on stderr")
+	scanCmd.Flags().Bool("no-progress", false, "Do not show the progress of the scan on stderr")
+	scanCmd.Flags().Duration("max-scan-time", 0, "Stop the scan after this time and show partial results (0 means no limit)")
 }
@@ -0,0 +1,90 @@
+/*
+Copyright © 2023 Still Learning LLC
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <http://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+// ansiColors maps the color names accepted by the color template function
+// to their ANSI escape codes.
+var ansiColors = map[string]string{
+	"bold":    "\033[1m",
+	"red":     "\033[31m",
+	"green":   "\033[32m",
+	"yellow":  "\033[33m",
+	"blue":    "\033[34m",
+	"magenta": "\033[35m",
+	"cyan":    "\033[36m",
+}
+
+// templateFuncs are the helper functions available to --template.
+var templateFuncs = template.FuncMap{
+	// openPorts returns the open ports of a host.
+	"openPorts": func(r scan.Results) []scan.PortState {
+		var open []scan.PortState
+
+		for _, p := range r.PortStates {
+			if p.State == scan.StateOpen {
+				open = append(open, p)
+			}
+		}
+
+		return open
+	},
+	// serviceName returns the service usually found on a TCP port.
+	"serviceName": scan.ServiceName,
+	// join joins the elements of any slice with sep.
+	"join": func(sep string, elems any) (string, error) {
+		v := reflect.ValueOf(elems)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return "", fmt.Errorf("join: expected a slice, got %T", elems)
+		}
+
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, fmt.Sprint(v.Index(i).Interface()))
+		}
+
+		return strings.Join(items, sep), nil
+	},
+	// color wraps s in the ANSI escape codes for the color name.
+	"color": func(name string, s any) (string, error) {
+		code, ok := ansiColors[name]
+		if !ok {
+			return "", fmt.Errorf("color: unknown color %q", name)
+		}
+
+		return fmt.Sprintf("%s%v\033[0m", code, s), nil
+	},
+}
+
+// parseTemplate parses a user supplied output template.
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("output").Funcs(templateFuncs).Parse(text)
+}
+
+// writeTemplate renders the results slice with the template.
+func writeTemplate(out io.Writer, tmpl *template.Template, r scanReport) error {
+	return tmpl.Execute(out, r.Results)
+}
@@ -0,0 +1,69 @@
+package scan
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Defaults for banner grabbing, used when the configuration does not set
+// them.
+const (
+	DefaultBannerSize    = 256
+	DefaultBannerTimeout = 500 * time.Millisecond
+)
+
+// grabBanner reads what the server sends right after the connection is
+// established, up to size bytes or until timeout expires.
+func grabBanner(conn net.Conn, size int, timeout time.Duration) string {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return ""
+	}
+
+	buf := make([]byte, size)
+	n := 0
+
+	// Servers may send their greeting in several packets, so keep reading
+	// until the buffer is full, the server closes or the deadline expires.
+	for n < size {
+		m, err := conn.Read(buf[n:])
+		n += m
+
+		if err != nil {
+			break
+		}
+	}
+
+	return sanitizeBanner(buf[:n])
+}
+
+// sanitizeBanner converts raw banner bytes to a single printable line.
+// Trailing white space is removed, line breaks are written as \n and any
+// other non-printable byte as \xNN.
+func sanitizeBanner(b []byte) string {
+	var sb strings.Builder
+
+	s := strings.TrimRight(string(b), " \t\r\n\x00")
+
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+
+		switch {
+		case s[:size] == "\r":
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\t':
+			sb.WriteByte(' ')
+		case r == utf8.RuneError && size == 1, r < 0x20, r == 0x7f:
+			fmt.Fprintf(&sb, `\x%02x`, s[0])
+		default:
+			sb.WriteString(s[:size])
+		}
+
+		s = s[size:]
+	}
+
+	return sb.String()
+}
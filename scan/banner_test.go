@@ -0,0 +1,61 @@
+package scan_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Dbaker1298/pScan/scan"
+	"github.com/Dbaker1298/pScan/scan/scantest"
+)
+
+func TestRunBanners(t *testing.T) {
+	testCases := []struct {
+		name     string
+		size     int
+		greeting string
+		expected string
+	}{
+		{"SSH", 0, "SSH-2.0-OpenSSH_9.3\r\n", "SSH-2.0-OpenSSH_9.3"},
+		{"MultiLine", 0, "220-mail.example.com ESMTP\r\n220 ready\r\n", `220-mail.example.com ESMTP\n220 ready`},
+		{"Binary", 0, "\x00\x01hi\xff\tthere\x7f", `\x00\x01hi\xff there\x7f`},
+		{"UTF8", 0, "héllo\n", "héllo"},
+		{"Silent", 0, "", ""},
+		{"Truncated", 8, "0123456789", "01234567"},
+	}
+
+	hl := &scan.HostsList{}
+	hl.Add("web.test")
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n := scantest.NewNetwork()
+			n.AddHost("web.test").TCP(22, scan.StateOpen).WithBanner(tc.greeting)
+
+			cfg := scan.Config{Banners: true, BannerSize: tc.size, BannerTimeout: time.Second, Dialer: n, Resolver: n}
+
+			res := scan.RunWithConfig(hl, []int{22}, cfg)
+
+			if len(res) != 1 || len(res[0].PortStates) != 1 {
+				t.Fatalf("Expected 1 port state, got %+v instead\n", res)
+			}
+
+			if b := res[0].PortStates[0].Banner; b != tc.expected {
+				t.Errorf("Expected banner %q, got %q instead\n", tc.expected, b)
+			}
+		})
+	}
+}
+
+func TestRunNoBanners(t *testing.T) {
+	hl := &scan.HostsList{}
+	hl.Add("web.test")
+
+	n := scantest.NewNetwork()
+	n.AddHost("web.test").TCP(22, scan.StateOpen).WithBanner("SSH-2.0-OpenSSH_9.3\r\n")
+
+	res := scan.RunWithConfig(hl, []int{22}, scan.Config{Dialer: n, Resolver: n})
+
+	if b := res[0].PortStates[0].Banner; b != "" {
+		t.Errorf("Expected no banner, got %q instead\n", b)
+	}
+}
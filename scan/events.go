@@ -0,0 +1,65 @@
+package scan
+
+import "context"
+
+// EventType identifies what happened during a scan.
+type EventType string
+
+const (
+	// EventHostResolved is sent once the addresses of a host are looked up,
+	// before any of its ports is scanned.
+	EventHostResolved EventType = "host-resolved"
+	// EventPortResult is sent once a port is scanned.
+	EventPortResult EventType = "port-result"
+	// EventHostDone is sent once a host is done: all its ports are scanned,
+	// it was not found, or the scan was interrupted.
+	EventHostDone EventType = "host-done"
+	// EventScanDone is the last event of a scan.
+	EventScanDone EventType = "scan-done"
+)
+
+// Event reports the progress of a scan, as sent by Scanner.Stream.
+type Event struct {
+	Type EventType
+	// Index is the position of the host among all the hosts of the scan, in
+	// the order they were expanded from the targets, which is also the
+	// order ScanHosts returns them in.
+	Index int
+	Host  string
+	// Addrs holds the addresses of the host for EventHostResolved.
+	Addrs []string
+	// Port holds the state of the port scanned for EventPortResult.
+	Port *PortState
+	// Results holds the results of the host for EventHostDone. When the scan
+	// was interrupted, they only have the ports scanned until then.
+	Results *Results
+	// Err is the reason the scan stopped early for EventScanDone, such as
+	// ctx.Err(), or nil when it finished.
+	Err error
+}
+
+// Stream scans the ports of the targets, which can be hostnames, IP
+// addresses, CIDR blocks and ranges, as in a HostsList, and sends the
+// events of the scan as they happen on the returned channel.
+//
+// Events of different hosts interleave, as hosts are scanned concurrently.
+// Each host found gets an EventHostResolved, then an EventPortResult per
+// port and an EventHostDone. Hosts not found only get an EventHostDone,
+// with Results.NotFound set. Hosts interrupted by ctx before they were
+// looked up get no events at all. The channel is closed right after the
+// EventScanDone, and callers must read it until then, or the scan blocks.
+func (s *Scanner) Stream(ctx context.Context, targets []string, ports []int) <-chan Event {
+	out := make(chan Event)
+
+	go func() {
+		defer close(out)
+
+		emit := func(ev Event) { out <- ev }
+
+		_, err := s.scan(ctx, ports, expandTargets(ctx, targets), emit)
+
+		emit(Event{Type: EventScanDone, Err: err})
+	}()
+
+	return out
+}
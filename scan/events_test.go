@@ -0,0 +1,93 @@
+package scan_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Dbaker1298/pScan/scan"
+	"github.com/Dbaker1298/pScan/scan/scantest"
+)
+
+func TestScannerStreamEvents(t *testing.T) {
+	n := testNetwork()
+	s := scan.NewScanner(scan.WithDialer(n), scan.WithResolver(n))
+
+	var (
+		events []scan.Event
+		seen   = map[string][]scan.EventType{}
+	)
+
+	for ev := range s.Stream(context.Background(), []string{"web.test", "gone.test", "10.0.0.0/31"}, []int{22, 80}) {
+		events = append(events, ev)
+
+		if ev.Type != scan.EventScanDone {
+			seen[ev.Host] = append(seen[ev.Host], ev.Type)
+		}
+	}
+
+	if last := events[len(events)-1]; last.Type != scan.EventScanDone || last.Err != nil {
+		t.Fatalf("Expected the last event to be a successful scan-done, got %+v instead\n", last)
+	}
+
+	expected := []scan.EventType{scan.EventHostResolved, scan.EventPortResult, scan.EventPortResult, scan.EventHostDone}
+
+	for _, host := range []string{"web.test", "10.0.0.0", "10.0.0.1"} {
+		if len(seen[host]) != len(expected) {
+			t.Errorf("Expected events %v for %s, got %v instead\n", expected, host, seen[host])
+			continue
+		}
+
+		for i := range expected {
+			if seen[host][i] != expected[i] {
+				t.Errorf("Expected events %v for %s, got %v instead\n", expected, host, seen[host])
+				break
+			}
+		}
+	}
+
+	if len(seen["gone.test"]) != 1 || seen["gone.test"][0] != scan.EventHostDone {
+		t.Errorf("Expected a single host-done for gone.test, got %v instead\n", seen["gone.test"])
+	}
+
+	indexes := map[string]int{"web.test": 0, "gone.test": 1, "10.0.0.0": 2, "10.0.0.1": 3}
+
+	for _, ev := range events {
+		if ev.Type != scan.EventHostDone {
+			continue
+		}
+
+		if ev.Index != indexes[ev.Host] {
+			t.Errorf("Expected index %d for %s, got %d instead\n", indexes[ev.Host], ev.Host, ev.Index)
+		}
+
+		if ev.Results == nil || ev.Results.Host != ev.Host || ev.Results.NotFound != (ev.Host == "gone.test") {
+			t.Errorf("Unexpected results for %s: %+v\n", ev.Host, ev.Results)
+		}
+	}
+}
+
+func TestScannerStreamCanceled(t *testing.T) {
+	n := scantest.NewNetwork()
+	n.AddHost("web.test").TCP(22, scan.StateOpen)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := scan.NewScanner(scan.WithDialer(n), scan.WithResolver(n), scan.WithConcurrency(1))
+
+	var last scan.Event
+
+	for ev := range s.Stream(ctx, []string{"web.test", "10.0.0.0/24"}, []int{22}) {
+		// Stop at the first port, the events must still end with scan-done.
+		if ev.Type == scan.EventPortResult {
+			cancel()
+		}
+
+		last = ev
+	}
+
+	if last.Type != scan.EventScanDone || !errors.Is(last.Err, context.Canceled) {
+		t.Errorf("Expected a canceled scan-done last, got %+v instead\n", last)
+	}
+}
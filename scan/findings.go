@@ -0,0 +1,144 @@
+package scan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultCertExpiryDays is how many days before its expiry a certificate
+// is reported when the configuration does not set it.
+const DefaultCertExpiryDays = 30
+
+// Severity ranks how serious a finding is.
+type Severity int
+
+// Severities of the findings, from the lowest to the highest.
+const (
+	SeverityInfo Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+// severities lists the severities from the lowest to the highest.
+var severities = []Severity{SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// String converts the severity to a human-readable string.
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityLow:
+		return "low"
+	case SeverityMedium:
+		return "medium"
+	case SeverityHigh:
+		return "high"
+	case SeverityCritical:
+		return "critical"
+	}
+
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText encodes the severity as its string, so it is readable in
+// formats such as JSON.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a severity encoded by MarshalText.
+func (s *Severity) UnmarshalText(text []byte) error {
+	for _, sv := range severities {
+		if sv.String() == string(text) {
+			*s = sv
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown severity %q", text)
+}
+
+// Finding is a problem found on a port by one of the checks.
+type Finding struct {
+	Port int `json:"port"`
+	// Address is the address of the host the port was scanned on.
+	Address string `json:"address,omitempty"`
+	// Check is the name of the check that reported the finding, such as
+	// "tls".
+	Check string `json:"check"`
+	// ID identifies the kind of problem, such as "cert-expired".
+	ID       string   `json:"id"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// IDs of the findings reported by the tls check.
+const (
+	FindingCertExpired          = "cert-expired"
+	FindingCertExpiring         = "cert-expiring"
+	FindingCertSelfSigned       = "cert-self-signed"
+	FindingCertUntrusted        = "cert-untrusted"
+	FindingCertHostnameMismatch = "cert-hostname-mismatch"
+	FindingTLSLegacyVersion     = "tls-legacy-version"
+	FindingTLSWeakCipher        = "tls-weak-cipher"
+)
+
+// tlsFindings reports the problems with the TLS sessions and certificates
+// found on ports. Certificates expiring within expiryDays are reported.
+func tlsFindings(ports []PortState, expiryDays int) []Finding {
+	var findings []Finding
+
+	for _, p := range ports {
+		info := p.TLS
+		if info == nil {
+			continue
+		}
+
+		add := func(id string, severity Severity, format string, a ...any) {
+			findings = append(findings, Finding{
+				Port:     p.Port,
+				Address:  p.Address,
+				Check:    "tls",
+				ID:       id,
+				Severity: severity,
+				Message:  fmt.Sprintf(format, a...),
+			})
+		}
+
+		switch {
+		case info.DaysToExpiry < 0:
+			add(FindingCertExpired, SeverityCritical, "certificate expired on %s", info.NotAfter.Format("2006-01-02"))
+		case info.DaysToExpiry < expiryDays:
+			add(FindingCertExpiring, SeverityMedium, "certificate expires in %d days, on %s", info.DaysToExpiry, info.NotAfter.Format("2006-01-02"))
+		}
+
+		// Expired and self-signed certificates also fail verification, so
+		// only report the chain when it fails for another reason.
+		switch {
+		case info.SelfSigned && !info.Verified:
+			add(FindingCertSelfSigned, SeverityHigh, "certificate for %s is self-signed", info.Subject)
+		case !info.Verified && info.DaysToExpiry >= 0:
+			add(FindingCertUntrusted, SeverityHigh, "certificate chain is not trusted: %s", info.VerifyError)
+		}
+
+		if !info.HostnameMatch {
+			if len(info.SANs) > 0 {
+				add(FindingCertHostnameMismatch, SeverityHigh, "certificate is not valid for the host, only for %s", strings.Join(info.SANs, ", "))
+			} else {
+				add(FindingCertHostnameMismatch, SeverityHigh, "certificate is not valid for the host")
+			}
+		}
+
+		if len(info.LegacyVersions) > 0 {
+			add(FindingTLSLegacyVersion, SeverityMedium, "deprecated %s enabled", strings.Join(info.LegacyVersions, " and "))
+		}
+
+		if len(info.WeakCiphers) > 0 {
+			add(FindingTLSWeakCipher, SeverityHigh, "weak cipher suites enabled: %s", strings.Join(info.WeakCiphers, ", "))
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,85 @@
+package scan_test
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+func TestRunCheckTLS(t *testing.T) {
+	srv := tlsServer(t)
+
+	// Accept TLS 1.0 and 1.1, and RC4 along with the usual cipher suites.
+	srv.TLS.MinVersion = tls.VersionTLS10
+	srv.TLS.CipherSuites = []uint16{
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_RSA_WITH_RC4_128_SHA,
+	}
+
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+
+	cfg := scan.Config{CheckTLS: true, CertExpiryDays: 100000}
+
+	res := scan.RunWithConfig(hl, []int{serverPort(t, srv)}, cfg)
+
+	info := res[0].PortStates[0].TLS
+	if info == nil {
+		t.Fatal("Expected TLS information, got nil instead")
+	}
+
+	if expected := []string{"TLS 1.0", "TLS 1.1"}; !reflect.DeepEqual(info.LegacyVersions, expected) {
+		t.Errorf("Expected legacy versions %v, got %v instead\n", expected, info.LegacyVersions)
+	}
+
+	if expected := []string{"TLS_RSA_WITH_RC4_128_SHA"}; !reflect.DeepEqual(info.WeakCiphers, expected) {
+		t.Errorf("Expected weak ciphers %v, got %v instead\n", expected, info.WeakCiphers)
+	}
+
+	expected := []struct {
+		id       string
+		severity scan.Severity
+	}{
+		{scan.FindingCertExpiring, scan.SeverityMedium},
+		{scan.FindingCertSelfSigned, scan.SeverityHigh},
+		{scan.FindingCertHostnameMismatch, scan.SeverityHigh},
+		{scan.FindingTLSLegacyVersion, scan.SeverityMedium},
+		{scan.FindingTLSWeakCipher, scan.SeverityHigh},
+	}
+
+	findings := res[0].Findings
+
+	if len(findings) != len(expected) {
+		t.Fatalf("Expected %d findings, got %d instead: %+v\n", len(expected), len(findings), findings)
+	}
+
+	for i, e := range expected {
+		f := findings[i]
+
+		if f.ID != e.id || f.Severity != e.severity || f.Check != "tls" || f.Port != serverPort(t, srv) || f.Message == "" {
+			t.Errorf("Expected finding %s with severity %s, got %+v instead\n", e.id, e.severity, f)
+		}
+	}
+}
+
+func TestSeverityText(t *testing.T) {
+	for _, name := range []string{"info", "low", "medium", "high", "critical"} {
+		var s scan.Severity
+
+		if err := s.UnmarshalText([]byte(name)); err != nil {
+			t.Fatalf("Expected no error for %q, got: %q\n", name, err)
+		}
+
+		if s.String() != name {
+			t.Errorf("Expected %q, got %q instead\n", name, s)
+		}
+	}
+
+	var s scan.Severity
+	if err := s.UnmarshalText([]byte("severe")); err == nil {
+		t.Error("Expected error for unknown severity, got nil")
+	}
+}
@@ -32,8 +32,13 @@ func (hl *HostsList) search(host string) (bool, int) {
 	return false, -1
 }
 
-// Add adds host to the list.
+// Add adds host to the list. The host can also be a CIDR block or a range
+// of addresses, as accepted by ExpandHost.
 func (hl *HostsList) Add(host string) error {
+	if _, _, _, err := parseEntry(host); err != nil {
+		return err
+	}
+
 	if found, _ := hl.search(host); found {
 		return fmt.Errorf("%w: %s", ErrExists, host)
 	}
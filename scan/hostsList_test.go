@@ -18,6 +18,10 @@ func TestAdd(t *testing.T) {
 	}{
 		{"AddNew", "host2", 2, nil},
 		{"AddExisting", "host1", 1, scan.ErrExists},
+		{"AddCIDR", "10.0.0.0/24", 2, nil},
+		{"AddRange", "10.0.0.1-50", 2, nil},
+		{"AddInvalidCIDR", "10.0.0.0/33", 1, scan.ErrInvalidHost},
+		{"AddInvalidRange", "10.0.0.50-1", 1, scan.ErrInvalidHost},
 	}
 
 	for _, tc := range testCases {
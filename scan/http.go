@@ -0,0 +1,163 @@
+package scan
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Defaults for HTTP enumeration, used when the configuration does not set
+// them.
+const (
+	DefaultHTTPTimeout = 5 * time.Second
+	DefaultHTTPMethod  = http.MethodGet
+)
+
+// maxTitleBody is how much of the page is read to find its title.
+const maxTitleBody = 64 << 10
+
+// HTTPInfo describes the response of a web server to a request for its
+// root page.
+type HTTPInfo struct {
+	// URL is the requested page, such as "https://example.com:8443/".
+	URL string `json:"url"`
+	// Method is the request method, GET or HEAD.
+	Method string `json:"method"`
+	Status int    `json:"status"`
+	// Server is the Server response header.
+	Server string `json:"server,omitempty"`
+	// Title is the page title. Only set for GET requests.
+	Title string `json:"title,omitempty"`
+	// Location is where redirect responses point to. Redirects are not
+	// followed.
+	Location string `json:"location,omitempty"`
+	// HSTS, CSP and XFrameOptions are the Strict-Transport-Security,
+	// Content-Security-Policy and X-Frame-Options security headers,
+	// empty when the server does not send them.
+	HSTS          string `json:"hsts,omitempty"`
+	CSP           string `json:"csp,omitempty"`
+	XFrameOptions string `json:"x_frame_options,omitempty"`
+}
+
+// SecurityHeaders returns the names of the security headers sent by the
+// server.
+func (h *HTTPInfo) SecurityHeaders() []string {
+	var names []string
+
+	for _, hdr := range []struct{ name, value string }{
+		{"Strict-Transport-Security", h.HSTS},
+		{"Content-Security-Policy", h.CSP},
+		{"X-Frame-Options", h.XFrameOptions},
+	} {
+		if hdr.value != "" {
+			names = append(names, hdr.name)
+		}
+	}
+
+	return names
+}
+
+// httpSchemes maps service names, as found by the probes or in the services
+// table, to the scheme used to request their pages.
+var httpSchemes = map[string]string{
+	"http":      "http",
+	"http-alt":  "http",
+	"https":     "https",
+	"https-alt": "https",
+}
+
+// httpScheme returns the scheme for the port, based on the identified
+// service when there is one, or the port number otherwise. It returns an
+// empty string for ports not serving HTTP.
+func httpScheme(port int, svc *Service) string {
+	if svc != nil {
+		return httpSchemes[svc.Name]
+	}
+
+	return httpSchemes[ServiceName(port)]
+}
+
+// titlePattern finds the title in an HTML page.
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// enumerateHTTP requests the root page of the web server on the open port
+// at address. It returns nil when the port does not serve HTTP or the
+// request fails.
+func enumerateHTTP(ctx context.Context, host, address string, port int, svc *Service, cfg Config) *HTTPInfo {
+	scheme := httpScheme(port, svc)
+	if scheme == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.httpTimeout())
+	defer cancel()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			// Connect to the scanned address, while the URL keeps the host
+			// name for the Host header and SNI.
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return cfg.dialer().DialContext(ctx, network, address)
+			},
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	info := &HTTPInfo{
+		URL:    (&url.URL{Scheme: scheme, Host: net.JoinHostPort(host, fmt.Sprint(port)), Path: "/"}).String(),
+		Method: cfg.httpMethod(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, info.Method, info.URL, nil)
+	if err != nil {
+		return nil
+	}
+
+	req.Header.Set("User-Agent", "pScan")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	info.Status = resp.StatusCode
+	info.Server = resp.Header.Get("Server")
+	info.Location = resp.Header.Get("Location")
+	info.HSTS = resp.Header.Get("Strict-Transport-Security")
+	info.CSP = resp.Header.Get("Content-Security-Policy")
+	info.XFrameOptions = resp.Header.Get("X-Frame-Options")
+
+	if info.Method == http.MethodGet {
+		info.Title = pageTitle(resp.Body)
+	}
+
+	return info
+}
+
+// pageTitle returns the title of the HTML page read from r, with entities
+// decoded and white space collapsed.
+func pageTitle(r io.Reader) string {
+	// A page cut short may still have its title, so read errors are
+	// ignored.
+	body, _ := io.ReadAll(io.LimitReader(r, maxTitleBody))
+
+	m := titlePattern.FindSubmatch(body)
+	if m == nil {
+		return ""
+	}
+
+	return strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+}
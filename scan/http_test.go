@@ -0,0 +1,126 @@
+package scan_test
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+func TestRunHTTP(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.25.3")
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		w.Header().Set("X-Frame-Options", "DENY")
+		fmt.Fprint(w, "<html><head>\n<TITLE>\n  Grafana &amp; friends\n</TITLE></head></html>")
+	})
+
+	redirect := http.RedirectHandler("https://login.example.com/", http.StatusFound)
+
+	newServer := func(t *testing.T, h http.Handler, tls bool) int {
+		srv := httptest.NewUnstartedServer(h)
+		srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+
+		if tls {
+			srv.StartTLS()
+		} else {
+			srv.Start()
+		}
+
+		t.Cleanup(srv.Close)
+
+		return serverPort(t, srv)
+	}
+
+	testCases := []struct {
+		name     string
+		handler  http.Handler
+		tls      bool
+		method   string
+		expected scan.HTTPInfo
+	}{
+		{
+			name:    "Page",
+			handler: mux,
+			expected: scan.HTTPInfo{
+				Method: "GET", Status: 200, Server: "nginx/1.25.3", Title: "Grafana & friends",
+				HSTS: "max-age=31536000", XFrameOptions: "DENY",
+			},
+		},
+		{
+			name:     "Head",
+			handler:  mux,
+			method:   "HEAD",
+			expected: scan.HTTPInfo{Method: "HEAD", Status: 200, Server: "nginx/1.25.3", HSTS: "max-age=31536000", XFrameOptions: "DENY"},
+		},
+		{
+			name:     "Redirect",
+			handler:  redirect,
+			expected: scan.HTTPInfo{Method: "GET", Status: 302, Location: "https://login.example.com/"},
+		},
+		{
+			name:     "HTTPS",
+			handler:  mux,
+			tls:      true,
+			method:   "HEAD",
+			expected: scan.HTTPInfo{Method: "HEAD", Status: 200, Server: "nginx/1.25.3", HSTS: "max-age=31536000", XFrameOptions: "DENY"},
+		},
+	}
+
+	hl := &scan.HostsList{}
+	hl.Add("127.0.0.1")
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			port := newServer(t, tc.handler, tc.tls)
+
+			// The probes find the web servers on ports outside the services
+			// table.
+			cfg := scan.Config{HTTP: true, HTTPMethod: tc.method, Probes: scan.Probes()}
+
+			res := scan.RunWithConfig(hl, []int{port}, cfg)
+
+			info := res[0].PortStates[0].HTTP
+			if info == nil {
+				t.Fatal("Expected HTTP information, got nil instead")
+			}
+
+			scheme := "http"
+			if tc.tls {
+				scheme = "https"
+			}
+
+			expected := tc.expected
+			expected.URL = fmt.Sprintf("%s://127.0.0.1:%d/", scheme, port)
+
+			if !reflect.DeepEqual(*info, expected) {
+				t.Errorf("Expected %+v, got %+v instead\n", expected, *info)
+			}
+		})
+	}
+}
+
+func TestRunHTTPNotWeb(t *testing.T) {
+	port := probeServer(t, func(conn net.Conn) {
+		io.WriteString(conn, "SSH-2.0-OpenSSH_9.3p1\r\n")
+		io.Copy(io.Discard, conn)
+	})
+
+	hl := &scan.HostsList{}
+	hl.Add("127.0.0.1")
+
+	res := scan.RunWithConfig(hl, []int{port}, scan.Config{HTTP: true, Probes: scan.Probes()})
+
+	if info := res[0].PortStates[0].HTTP; info != nil {
+		t.Errorf("Expected no HTTP information, got %+v instead\n", info)
+	}
+}
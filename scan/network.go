@@ -0,0 +1,52 @@
+package scan
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Dialer opens the connections made by the scans, to the ports and to the
+// services behind them. *net.Dialer implements it, and so can proxies,
+// tunnels or fakes in tests.
+//
+// Dialers should report the failures as the net package does, with errors
+// wrapping syscall.ECONNREFUSED for closed ports and net.Error timeouts for
+// ports that do not answer, so the port states are told apart.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Resolver looks up the addresses of the hosts to scan. *net.Resolver
+// implements it.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// dialer returns the Dialer of the configuration, or a zero net.Dialer.
+func (c Config) dialer() Dialer {
+	if c.Dialer == nil {
+		return &net.Dialer{}
+	}
+
+	return c.Dialer
+}
+
+// resolver returns the Resolver of the configuration, or
+// net.DefaultResolver.
+func (c Config) resolver() Resolver {
+	if c.Resolver == nil {
+		return net.DefaultResolver
+	}
+
+	return c.Resolver
+}
+
+// dial connects to address with the Dialer of the configuration, giving up
+// after timeout. The connection outlives the timeout.
+func (c Config) dial(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return c.dialer().DialContext(ctx, network, address)
+}
@@ -0,0 +1,31 @@
+package scan_test
+
+import (
+	"testing"
+
+	"github.com/Dbaker1298/pScan/scan"
+	"github.com/Dbaker1298/pScan/scan/scantest"
+)
+
+// Test that the ports of hosts that are not found are never dialed
+func TestRunResolverError(t *testing.T) {
+	n := scantest.NewNetwork()
+
+	n.AddHost("web.test").TCP(80, scan.StateOpen)
+	n.FailLookup("gone.test", nil)
+
+	hl := &scan.HostsList{}
+
+	hl.Add("gone.test")
+	hl.Add("web.test")
+
+	res := scan.RunWithConfig(hl, []int{80}, scan.Config{Dialer: n, Resolver: n})
+
+	if len(res) != 2 || !res[0].NotFound || res[1].NotFound {
+		t.Fatalf("Expected gone.test not found and web.test found, got %v instead\n", res)
+	}
+
+	if dials := n.Dials(); len(dials) != 1 || dials[0] != "tcp 192.0.2.1:80" {
+		t.Errorf("Expected a single dial to 192.0.2.1:80, got %v instead\n", dials)
+	}
+}
@@ -0,0 +1,310 @@
+package scan
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrInvalidPortSpec = errors.New("Invalid port specification")
+
+// MaxPort is the highest valid TCP or UDP port number.
+const MaxPort = 65535
+
+// Protocols of the scanned ports, as found in PortState.Protocol.
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+//go:embed data/services
+var servicesData string
+
+//go:embed data/top-ports
+var topPortsData string
+
+// serviceTable holds the embedded services and top ports lists, parsed on
+// first use.
+type serviceTable struct {
+	// ports maps service names and aliases to ports by protocol.
+	ports map[string]map[string]int
+	// names maps "port/protocol" to the service name.
+	names map[string]string
+	// top lists ports from the most to the least common.
+	top []int
+}
+
+var (
+	services     serviceTable
+	servicesOnce sync.Once
+)
+
+// loadServices parses the embedded tables once and returns them.
+func loadServices() *serviceTable {
+	servicesOnce.Do(func() {
+		services.ports = map[string]map[string]int{}
+		services.names = map[string]string{}
+
+		scanner := bufio.NewScanner(strings.NewReader(servicesData))
+		for scanner.Scan() {
+			line, _, _ := strings.Cut(scanner.Text(), "#")
+
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+
+			portStr, proto, ok := strings.Cut(fields[1], "/")
+			if !ok {
+				continue
+			}
+
+			port, err := strconv.Atoi(portStr)
+			if err != nil {
+				continue
+			}
+
+			key := fmt.Sprintf("%d/%s", port, proto)
+			if _, ok := services.names[key]; !ok {
+				services.names[key] = fields[0]
+			}
+
+			// fields[0] is the name, the remaining fields are aliases.
+			names := append([]string{fields[0]}, fields[2:]...)
+
+			for _, name := range names {
+				name = strings.ToLower(name)
+
+				if services.ports[name] == nil {
+					services.ports[name] = map[string]int{}
+				}
+
+				if _, ok := services.ports[name][proto]; !ok {
+					services.ports[name][proto] = port
+				}
+			}
+		}
+
+		scanner = bufio.NewScanner(strings.NewReader(topPortsData))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			if port, err := strconv.Atoi(line); err == nil {
+				services.top = append(services.top, port)
+			}
+		}
+	})
+
+	return &services
+}
+
+// ServiceName returns the name of the service usually found on the TCP
+// port, or an empty string if it is unknown.
+func ServiceName(port int) string {
+	return LookupService(port, ProtocolTCP)
+}
+
+// LookupService returns the name of the service usually found on the port
+// for the protocol, tcp or udp, or an empty string if it is unknown.
+func LookupService(port int, proto string) string {
+	return loadServices().names[fmt.Sprintf("%d/%s", port, proto)]
+}
+
+// ParsePorts parses a port specification into a list of TCP ports. See
+// ParsePortSpec for the syntax. It fails if the specification selects UDP
+// ports.
+func ParsePorts(spec string) ([]int, error) {
+	tcp, udp, err := ParsePortSpec(spec, ProtocolTCP)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(udp) > 0 {
+		return nil, fmt.Errorf("%w: %q: UDP ports are not supported", ErrInvalidPortSpec, spec)
+	}
+
+	return tcp, nil
+}
+
+// ParsePortSpec parses a port specification into lists of TCP and UDP
+// ports. The specification is a comma separated list of items, each of them
+// being:
+//
+//   - a single port, such as 22
+//   - an inclusive range, such as 1-1024
+//   - a service name or alias, such as ssh or postgres
+//   - top:N, for the N most common TCP ports
+//
+// Items are ports of the proto protocol, tcp or udp, until an item prefixed
+// with T: or U: switches to TCP or UDP, such as in T:22,80,U:53,161. Any
+// item prefixed with ! is excluded from the result, such as !25 or
+// !8000-8100. The ! goes before or after the T: or U: prefix, so !U:53 and
+// U:!53 both exclude the UDP port 53, and the prefix of an excluded item
+// only applies to it. Ports are returned in the order they first appear in
+// the specification, without duplicates.
+func ParsePortSpec(spec, proto string) ([]int, []int, error) {
+	include := map[string][]int{}
+	exclude := map[string]map[int]bool{ProtocolTCP: {}, ProtocolUDP: {}}
+
+	if proto != ProtocolTCP && proto != ProtocolUDP {
+		return nil, nil, fmt.Errorf("%w: unknown protocol %q", ErrInvalidPortSpec, proto)
+	}
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		negate := strings.HasPrefix(item, "!")
+		item = strings.TrimSpace(strings.TrimPrefix(item, "!"))
+
+		itemProto := proto
+
+		switch prefix := strings.ToUpper(item[:min(len(item), 2)]); prefix {
+		case "T:":
+			itemProto, item = ProtocolTCP, strings.TrimSpace(item[2:])
+		case "U:":
+			itemProto, item = ProtocolUDP, strings.TrimSpace(item[2:])
+		}
+
+		if strings.HasPrefix(item, "!") {
+			if negate {
+				return nil, nil, fmt.Errorf("%w: %q: ! given twice", ErrInvalidPortSpec, spec)
+			}
+
+			negate = true
+			item = strings.TrimSpace(item[1:])
+		}
+
+		if item == "" {
+			return nil, nil, fmt.Errorf("%w: %q: missing port", ErrInvalidPortSpec, spec)
+		}
+
+		ports, err := parsePortItem(item, itemProto)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !negate {
+			proto = itemProto
+			include[proto] = append(include[proto], ports...)
+			continue
+		}
+
+		for _, p := range ports {
+			exclude[itemProto][p] = true
+		}
+	}
+
+	tcp := dedupePorts(include[ProtocolTCP], exclude[ProtocolTCP])
+	udp := dedupePorts(include[ProtocolUDP], exclude[ProtocolUDP])
+
+	if len(tcp) == 0 && len(udp) == 0 {
+		return nil, nil, fmt.Errorf("%w: %q selects no ports", ErrInvalidPortSpec, spec)
+	}
+
+	return tcp, udp, nil
+}
+
+// dedupePorts returns the ports in include that are not in exclude, in
+// order and without duplicates.
+func dedupePorts(include []int, exclude map[int]bool) []int {
+	seen := map[int]bool{}
+
+	var res []int
+
+	for _, p := range include {
+		if seen[p] || exclude[p] {
+			continue
+		}
+
+		seen[p] = true
+		res = append(res, p)
+	}
+
+	return res
+}
+
+// parsePortItem expands a single item of a port specification for the
+// protocol.
+func parsePortItem(item, proto string) ([]int, error) {
+	st := loadServices()
+
+	if prefix, n, ok := strings.Cut(item, ":"); ok && strings.EqualFold(prefix, "top") {
+		if proto != ProtocolTCP {
+			return nil, fmt.Errorf("%w: %q: top ports are only known for TCP", ErrInvalidPortSpec, item)
+		}
+
+		count, err := strconv.Atoi(n)
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("%w: %q: invalid number of top ports", ErrInvalidPortSpec, item)
+		}
+
+		if count > len(st.top) {
+			return nil, fmt.Errorf("%w: %q: only the top %d ports are known", ErrInvalidPortSpec, item, len(st.top))
+		}
+
+		return st.top[:count], nil
+	}
+
+	// Service names may contain dashes too, such as ftp-data.
+	if item[0] < '0' || item[0] > '9' {
+		if p, ok := st.ports[strings.ToLower(item)][proto]; ok {
+			return []int{p}, nil
+		}
+
+		return nil, fmt.Errorf("%w: %q: unknown service", ErrInvalidPortSpec, item)
+	}
+
+	if first, last, ok := strings.Cut(item, "-"); ok {
+		start, err := parsePort(first)
+		if err != nil {
+			return nil, err
+		}
+
+		end, err := parsePort(last)
+		if err != nil {
+			return nil, err
+		}
+
+		if start > end {
+			return nil, fmt.Errorf("%w: %q: range start is greater than its end", ErrInvalidPortSpec, item)
+		}
+
+		ports := make([]int, 0, end-start+1)
+		for p := start; p <= end; p++ {
+			ports = append(ports, p)
+		}
+
+		return ports, nil
+	}
+
+	p, err := parsePort(item)
+	if err != nil {
+		return nil, err
+	}
+
+	return []int{p}, nil
+}
+
+// parsePort converts s to a port number, checking it is in the valid range.
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not a port number", ErrInvalidPortSpec, s)
+	}
+
+	if p < 1 || p > MaxPort {
+		return 0, fmt.Errorf("%w: port %d out of range 1-%d", ErrInvalidPortSpec, p, MaxPort)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,154 @@
+package scan_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+func TestParsePorts(t *testing.T) {
+	testCases := []struct {
+		name      string
+		spec      string
+		expected  []int
+		expectErr error
+	}{
+		{"Single", "22", []int{22}, nil},
+		{"List", "22,80, 443", []int{22, 80, 443}, nil},
+		{"Range", "20-23", []int{20, 21, 22, 23}, nil},
+		{"Duplicates", "22,20-23,22", []int{22, 20, 21, 23}, nil},
+		{"Exclusion", "20-25,!22,!24-25", []int{20, 21, 23}, nil},
+		{"Services", "ssh,http,postgres", []int{22, 80, 5432}, nil},
+		{"ServiceWithDash", "ftp-data", []int{20}, nil},
+		{"ServiceCase", "HTTPS", []int{443}, nil},
+		{"Top", "top:5", []int{80, 23, 443, 21, 22}, nil},
+		{"TopExclusion", "top:5,!telnet", []int{80, 443, 21, 22}, nil},
+		{"TopUpperCase", "TOP:3", []int{80, 23, 443}, nil},
+		{"OutOfRange", "65536", nil, scan.ErrInvalidPortSpec},
+		{"Zero", "0-10", nil, scan.ErrInvalidPortSpec},
+		{"ReversedRange", "25-20", nil, scan.ErrInvalidPortSpec},
+		{"UnknownService", "nosuchservice", nil, scan.ErrInvalidPortSpec},
+		{"InvalidTop", "top:x", nil, scan.ErrInvalidPortSpec},
+		{"TooManyTop", "top:100000", nil, scan.ErrInvalidPortSpec},
+		{"EmptyExclusion", "22,!", nil, scan.ErrInvalidPortSpec},
+		{"NothingLeft", "22,!22", nil, scan.ErrInvalidPortSpec},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ports, err := scan.ParsePorts(tc.spec)
+
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) {
+					t.Fatalf("Expected error %q, got %v instead\n", tc.expectErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+
+			if !reflect.DeepEqual(ports, tc.expected) {
+				t.Errorf("Expected ports %v, got %v instead\n", tc.expected, ports)
+			}
+		})
+	}
+}
+
+func TestParsePortSpec(t *testing.T) {
+	testCases := []struct {
+		name        string
+		spec        string
+		proto       string
+		expectedTCP []int
+		expectedUDP []int
+		expectErr   error
+	}{
+		{"TCP", "22,80", "tcp", []int{22, 80}, nil, nil},
+		{"UDP", "53,161", "udp", nil, []int{53, 161}, nil},
+		{"Mixed", "T:22,80,U:53,snmp", "tcp", []int{22, 80}, []int{53, 161}, nil},
+		{"LowerCase", "u:ntp,t:ssh", "tcp", []int{22}, []int{123}, nil},
+		{"DefaultUDP", "53,T:22", "udp", []int{22}, []int{53}, nil},
+		{"Exclusion", "T:20-23,U:50-55,!53,!T:21", "tcp", []int{20, 22, 23}, []int{50, 51, 52, 54, 55}, nil},
+		{"ExclusionAfterPrefix", "T:20-23,U:50-55,U:!53,T:!21", "tcp", []int{20, 22, 23}, []int{50, 51, 52, 54, 55}, nil},
+		{"ExclusionKeepsProtocol", "22,!U:53,80", "tcp", []int{22, 80}, nil, nil},
+		{"DoubleExclusion", "22-25,!T:!23", "tcp", nil, nil, scan.ErrInvalidPortSpec},
+		{"UDPService", "U:wireguard", "tcp", nil, []int{51820}, nil},
+		{"TCPOnlyService", "U:ssh,U:ftp-data", "tcp", nil, nil, scan.ErrInvalidPortSpec},
+		{"UDPTop", "U:top:10", "tcp", nil, nil, scan.ErrInvalidPortSpec},
+		{"MissingPort", "T:", "tcp", nil, nil, scan.ErrInvalidPortSpec},
+		{"UnknownProtocol", "22", "sctp", nil, nil, scan.ErrInvalidPortSpec},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			tcp, udp, err := scan.ParsePortSpec(tc.spec, tc.proto)
+
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) {
+					t.Fatalf("Expected error %q, got %v instead\n", tc.expectErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+
+			if !reflect.DeepEqual(tcp, tc.expectedTCP) || !reflect.DeepEqual(udp, tc.expectedUDP) {
+				t.Errorf("Expected TCP %v and UDP %v, got %v and %v instead\n", tc.expectedTCP, tc.expectedUDP, tcp, udp)
+			}
+		})
+	}
+
+	if _, err := scan.ParsePorts("22,U:53"); !errors.Is(err, scan.ErrInvalidPortSpec) {
+		t.Errorf("Expected ParsePorts to reject UDP ports, got %v instead\n", err)
+	}
+}
+
+func TestServiceName(t *testing.T) {
+	testCases := []struct {
+		port     int
+		expected string
+	}{
+		{22, "ssh"},
+		{80, "http"},
+		{5432, "postgresql"},
+		{27017, "mongodb"},
+		{1, "tcpmux"},
+		{65000, ""},
+	}
+
+	for _, tc := range testCases {
+		if name := scan.ServiceName(tc.port); name != tc.expected {
+			t.Errorf("Expected service %q for port %d, got %q instead\n", tc.expected, tc.port, name)
+		}
+	}
+}
+
+func TestLookupService(t *testing.T) {
+	testCases := []struct {
+		port     int
+		proto    string
+		expected string
+	}{
+		{53, "udp", "domain"},
+		{161, "udp", "snmp"},
+		{51820, "udp", "wireguard"},
+		{22, "tcp", "ssh"},
+		{51820, "tcp", ""},
+	}
+
+	for _, tc := range testCases {
+		if name := scan.LookupService(tc.port, tc.proto); name != tc.expected {
+			t.Errorf("Expected service %q for port %d/%s, got %q instead\n", tc.expected, tc.port, tc.proto, name)
+		}
+	}
+}
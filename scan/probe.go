@@ -0,0 +1,174 @@
+package scan
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultProbeTimeout is how long a probe can take when the configuration
+// does not set it.
+const DefaultProbeTimeout = 2 * time.Second
+
+// DefaultIdentifyTimeout is how long identifying the service of a port can
+// take, all probes included, when the configuration does not set it.
+const DefaultIdentifyTimeout = 5 * time.Second
+
+// Service describes the software identified on an open port.
+type Service struct {
+	// Name is the protocol spoken on the port, such as "http" or "ssh".
+	Name string `json:"name"`
+	// Product and Version identify the software, when it tells them.
+	Product string `json:"product,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Probe is the name of the probe that identified the service. It is
+	// empty for services read from other scanners' results.
+	Probe string `json:"probe,omitempty"`
+}
+
+// Probe identifies the service listening on an open port by talking its
+// protocol.
+type Probe interface {
+	// Name returns a short name for the probe, such as "http".
+	Name() string
+	// Ports returns the ports the service usually listens on. The probe is
+	// tried first on those ports, and after the other probes on any other
+	// port.
+	Ports() []int
+	// Probe sends the protocol request, if any, over a new connection to
+	// the port and reads the response. It returns the service when the
+	// response matches the protocol, or nil otherwise. The connection
+	// deadline is already set when Probe is called.
+	Probe(ctx context.Context, conn net.Conn) (*Service, error)
+}
+
+// BannerMatcher is implemented by the probes of services that greet clients
+// as soon as they connect, such as SSH or SMTP. When banners are grabbed,
+// these probes are tried first on the ports whose banner they match, and
+// skipped on the other ports.
+type BannerMatcher interface {
+	// MatchBanner reports whether banner is the greeting of the service.
+	MatchBanner(banner string) bool
+}
+
+var (
+	probesMu sync.RWMutex
+	probes   []Probe
+)
+
+// RegisterProbe adds p to the probes returned by Probes. Probes registered
+// later are tried after the built-in ones.
+func RegisterProbe(p Probe) {
+	probesMu.Lock()
+	defer probesMu.Unlock()
+
+	probes = append(probes, p)
+}
+
+// Probes returns the registered probes, starting with the built-in ones:
+// tls, http, ssh, smtp, redis, postgresql, mysql and mongodb.
+func Probes() []Probe {
+	probesMu.RLock()
+	defer probesMu.RUnlock()
+
+	return append([]Probe(nil), probes...)
+}
+
+// LookupProbe returns the registered probe with the name, or nil if there
+// is none.
+func LookupProbe(name string) Probe {
+	for _, p := range Probes() {
+		if p.Name() == name {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// orderProbes returns the probes for port, starting with the ones that
+// match its banner, then the ones that list the port. When hasBanner is
+// true, the banner was grabbed, and the probes implementing BannerMatcher
+// that do not match it are left out.
+func orderProbes(probes []Probe, port int, banner string, hasBanner bool) []Probe {
+	matched := make([]Probe, 0, len(probes))
+	listed := make([]Probe, 0, len(probes))
+	rest := make([]Probe, 0, len(probes))
+
+	for _, p := range probes {
+		if m, ok := p.(BannerMatcher); ok && hasBanner {
+			if m.MatchBanner(banner) {
+				matched = append(matched, p)
+			}
+
+			continue
+		}
+
+		if containsPort(p.Ports(), port) {
+			listed = append(listed, p)
+			continue
+		}
+
+		rest = append(rest, p)
+	}
+
+	return append(append(matched, listed...), rest...)
+}
+
+// containsPort reports whether port is in ports.
+func containsPort(ports []int, port int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+
+	return false
+}
+
+// identify runs the configured probes against the open port p at address,
+// each one over its own connection, until one of them matches or the
+// identify timeout expires.
+func identify(ctx context.Context, address string, p *PortState, cfg Config) *Service {
+	ctx, cancel := context.WithTimeout(ctx, cfg.identifyTimeout())
+	defer cancel()
+
+	for _, pr := range orderProbes(cfg.Probes, p.Port, p.Banner, cfg.Banners) {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if svc := runProbe(ctx, pr, address, cfg); svc != nil {
+			svc.Probe = pr.Name()
+			return svc
+		}
+	}
+
+	return nil
+}
+
+// runProbe connects to address and runs a single probe, bounded by the
+// probe timeout and the deadline of ctx.
+func runProbe(ctx context.Context, p Probe, address string, cfg Config) *Service {
+	ctx, cancel := context.WithTimeout(ctx, cfg.probeTimeout())
+	defer cancel()
+
+	conn, err := cfg.dialer().DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil
+	}
+
+	svc, err := p.Probe(ctx, conn)
+	if err != nil {
+		return nil
+	}
+
+	return svc
+}
@@ -0,0 +1,337 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// errNoMatch is returned by the built-in probes when the response does not
+// match their protocol.
+var errNoMatch = errors.New("response does not match the protocol")
+
+// probe is a Probe defined by its fields.
+type probe struct {
+	name  string
+	ports []int
+	fn    func(context.Context, net.Conn) (*Service, error)
+}
+
+func (p probe) Name() string { return p.name }
+
+func (p probe) Ports() []int { return p.ports }
+
+func (p probe) Probe(ctx context.Context, conn net.Conn) (*Service, error) {
+	return p.fn(ctx, conn)
+}
+
+// NewProbe returns a Probe with the name and ports that identifies services
+// with fn.
+func NewProbe(name string, ports []int, fn func(ctx context.Context, conn net.Conn) (*Service, error)) Probe {
+	return probe{name: name, ports: ports, fn: fn}
+}
+
+// greetingProbe is a probe for a service that greets clients first, which
+// also recognizes the greeting in banners.
+type greetingProbe struct {
+	probe
+	match func(banner string) bool
+}
+
+func (p greetingProbe) MatchBanner(banner string) bool { return p.match(banner) }
+
+// newGreetingProbe returns a greetingProbe with the name and ports that
+// identifies services with fn, and their banners with match.
+func newGreetingProbe(name string, ports []int, fn func(context.Context, net.Conn) (*Service, error), match func(string) bool) Probe {
+	return greetingProbe{probe: probe{name: name, ports: ports, fn: fn}, match: match}
+}
+
+func init() {
+	// The tls probe goes first: most HTTPS servers answer plain HTTP
+	// requests with an error, which the http probe would match.
+	RegisterProbe(NewProbe("tls", []int{443, 465, 636, 853, 993, 995, 8443}, probeTLS))
+	RegisterProbe(NewProbe("http", []int{80, 81, 3000, 5000, 8000, 8008, 8080, 8081, 8888, 9200}, probeHTTP))
+	RegisterProbe(newGreetingProbe("ssh", []int{22, 2222}, probeSSH, isSSHGreeting))
+	RegisterProbe(newGreetingProbe("smtp", []int{25, 587}, probeSMTP, isSMTPGreeting))
+	RegisterProbe(NewProbe("redis", []int{6379}, probeRedis))
+	RegisterProbe(NewProbe("postgresql", []int{5432}, probePostgreSQL))
+	RegisterProbe(newGreetingProbe("mysql", []int{3306}, probeMySQL, isMySQLGreeting))
+	RegisterProbe(NewProbe("mongodb", []int{27017}, probeMongoDB))
+}
+
+// splitProduct splits a software identifier such as "nginx/1.25.3" or
+// "OpenSSH_9.3p1" in its product and version.
+func splitProduct(s, sep string) (string, string) {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), " ")
+	product, version, _ := strings.Cut(s, sep)
+
+	return product, version
+}
+
+// httpRequest is the request sent by the http and tls probes.
+const httpRequest = "GET / HTTP/1.0\r\nUser-Agent: pScan\r\nAccept: */*\r\n\r\n"
+
+// probeHTTP sends a GET request and matches an HTTP response. The product
+// comes from the Server header.
+func probeHTTP(ctx context.Context, conn net.Conn) (*Service, error) {
+	return httpService(conn, "http")
+}
+
+// httpService sends a GET request over conn, and returns a service with the
+// name if the response is HTTP.
+func httpService(conn net.Conn, name string) (*Service, error) {
+	if _, err := io.WriteString(conn, httpRequest); err != nil {
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return nil, errNoMatch
+	}
+	resp.Body.Close()
+
+	svc := &Service{Name: name}
+	svc.Product, svc.Version = splitProduct(resp.Header.Get("Server"), "/")
+
+	return svc, nil
+}
+
+// probeTLS performs a TLS handshake. Once established, it tries HTTP over
+// TLS to tell https apart from other TLS services.
+func probeTLS(ctx context.Context, conn net.Conn) (*Service, error) {
+	tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true}) //nolint:gosec
+
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, errNoMatch
+	}
+
+	if svc, err := httpService(tlsConn, "https"); err == nil {
+		return svc, nil
+	}
+
+	return &Service{Name: "tls"}, nil
+}
+
+// probeSSH matches the SSH identification string, such as
+// "SSH-2.0-OpenSSH_9.3p1 Debian-1".
+func probeSSH(ctx context.Context, conn net.Conn) (*Service, error) {
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return nil, err
+	}
+
+	if !isSSHGreeting(line) {
+		return nil, errNoMatch
+	}
+
+	// The software version comes after the protocol version, SSH-2.0-.
+	parts := strings.SplitN(strings.TrimSpace(line), "-", 3)
+	if len(parts) < 3 {
+		return &Service{Name: "ssh"}, nil
+	}
+
+	svc := &Service{Name: "ssh"}
+	svc.Product, svc.Version = splitProduct(parts[2], "_")
+
+	return svc, nil
+}
+
+// smtpProducts finds well known mail servers in SMTP greetings.
+var smtpProducts = regexp.MustCompile(`(?i)\b(Postfix|Exim|Sendmail|Microsoft ESMTP MAIL Service|OpenSMTPD|Haraka)\b(?:[ /]([0-9][\w.]*))?`)
+
+// probeSMTP matches the SMTP greeting, such as
+// "220 mail.example.com ESMTP Postfix".
+func probeSMTP(ctx context.Context, conn net.Conn) (*Service, error) {
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return nil, err
+	}
+
+	if !isSMTPGreeting(line) {
+		return nil, errNoMatch
+	}
+
+	svc := &Service{Name: "smtp"}
+
+	if m := smtpProducts.FindStringSubmatch(line); m != nil {
+		svc.Product, svc.Version = m[1], m[2]
+	}
+
+	return svc, nil
+}
+
+// isSSHGreeting reports whether banner starts with the SSH identification
+// string.
+func isSSHGreeting(banner string) bool {
+	return strings.HasPrefix(banner, "SSH-")
+}
+
+// isSMTPGreeting reports whether banner starts with an SMTP greeting. FTP
+// servers greet with 220 too, so it looks for SMTP in the greeting.
+func isSMTPGreeting(banner string) bool {
+	return strings.HasPrefix(banner, "220") && strings.Contains(strings.ToUpper(banner), "SMTP")
+}
+
+// probeRedis sends PING, and then asks for the server version when the
+// server does not require authentication.
+func probeRedis(ctx context.Context, conn net.Conn) (*Service, error) {
+	r := bufio.NewReader(conn)
+
+	if _, err := io.WriteString(conn, "PING\r\n"); err != nil {
+		return nil, err
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, errNoMatch
+	}
+
+	svc := &Service{Name: "redis", Product: "Redis"}
+
+	switch {
+	case strings.HasPrefix(line, "-NOAUTH"), strings.HasPrefix(line, "-DENIED"):
+		return svc, nil
+	case !strings.HasPrefix(line, "+PONG"):
+		return nil, errNoMatch
+	}
+
+	if _, err := io.WriteString(conn, "INFO server\r\n"); err != nil {
+		return svc, nil
+	}
+
+	// The reply is a bulk string, one "field:value" per line.
+	for {
+		line, err := r.ReadString('\n')
+		if v, ok := strings.CutPrefix(line, "redis_version:"); ok {
+			svc.Version = strings.TrimSpace(v)
+			break
+		}
+
+		if err != nil || line == "\r\n" {
+			break
+		}
+	}
+
+	return svc, nil
+}
+
+// probePostgreSQL sends an SSLRequest message. PostgreSQL servers answer
+// with a single byte, S or N, whether they support TLS or not.
+func probePostgreSQL(ctx context.Context, conn net.Conn) (*Service, error) {
+	req := make([]byte, 8)
+	binary.BigEndian.PutUint32(req[0:4], 8)
+	binary.BigEndian.PutUint32(req[4:8], 80877103)
+
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	resp := make([]byte, 2)
+
+	n, _ := io.ReadAtLeast(conn, resp, 1)
+	if n != 1 || (resp[0] != 'S' && resp[0] != 'N') {
+		return nil, errNoMatch
+	}
+
+	return &Service{Name: "postgresql", Product: "PostgreSQL"}, nil
+}
+
+// probeMySQL reads the initial handshake packet, which has the server
+// version, such as "8.0.35" or "5.5.5-10.11.4-MariaDB".
+func probeMySQL(ctx context.Context, conn net.Conn) (*Service, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, errNoMatch
+	}
+
+	size := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	if size < 2 || size > 1024 {
+		return nil, errNoMatch
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return nil, errNoMatch
+	}
+
+	// Protocol version 10 followed by the NUL terminated server version.
+	end := bytes.IndexByte(payload[1:], 0)
+	if payload[0] != 10 || end < 0 {
+		return nil, errNoMatch
+	}
+
+	svc := &Service{Name: "mysql", Product: "MySQL", Version: string(payload[1 : end+1])}
+
+	// MariaDB prefixes its version with 5.5.5- for old clients.
+	if strings.Contains(svc.Version, "MariaDB") {
+		svc.Product = "MariaDB"
+		svc.Version = strings.TrimPrefix(svc.Version, "5.5.5-")
+		svc.Version, _, _ = strings.Cut(svc.Version, "-")
+	}
+
+	return svc, nil
+}
+
+// isMySQLGreeting reports whether banner is a MySQL initial handshake
+// packet. In the sanitized banner, the packet length comes first, then the
+// sequence number 0, written \x00, the protocol version 10, written \n,
+// and the server version.
+func isMySQLGreeting(banner string) bool {
+	i := strings.Index(banner, `\x00\n`)
+	if i < 3 || i > 12 || len(banner) <= i+6 {
+		return false
+	}
+
+	return banner[i+6] >= '0' && banner[i+6] <= '9'
+}
+
+// mongoRequestID identifies the isMaster query sent by probeMongoDB.
+const mongoRequestID = 0x70536361
+
+// probeMongoDB sends an isMaster command with the legacy OP_QUERY opcode,
+// which every MongoDB version accepts before authentication, and matches
+// the OP_REPLY answer.
+func probeMongoDB(ctx context.Context, conn net.Conn) (*Service, error) {
+	// BSON document {isMaster: 1}.
+	doc := []byte{19, 0, 0, 0, 0x10, 'i', 's', 'M', 'a', 's', 't', 'e', 'r', 0, 1, 0, 0, 0, 0}
+
+	var body bytes.Buffer
+	binary.Write(&body, binary.LittleEndian, int32(0)) // flags
+	body.WriteString("admin.$cmd\x00")
+	binary.Write(&body, binary.LittleEndian, int32(0)) // numberToSkip
+	binary.Write(&body, binary.LittleEndian, int32(1)) // numberToReturn
+	body.Write(doc)
+
+	var msg bytes.Buffer
+	binary.Write(&msg, binary.LittleEndian, int32(16+body.Len()))
+	binary.Write(&msg, binary.LittleEndian, int32(mongoRequestID))
+	binary.Write(&msg, binary.LittleEndian, int32(0))    // responseTo
+	binary.Write(&msg, binary.LittleEndian, int32(2004)) // OP_QUERY
+	msg.Write(body.Bytes())
+
+	if _, err := conn.Write(msg.Bytes()); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, errNoMatch
+	}
+
+	responseTo := binary.LittleEndian.Uint32(header[8:12])
+	opCode := binary.LittleEndian.Uint32(header[12:16])
+
+	if responseTo != mongoRequestID || opCode != 1 {
+		return nil, errNoMatch
+	}
+
+	return &Service{Name: "mongodb", Product: "MongoDB"}, nil
+}
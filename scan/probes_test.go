@@ -0,0 +1,336 @@
+package scan_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dbaker1298/pScan/scan"
+	"github.com/Dbaker1298/pScan/scan/scantest"
+)
+
+// probeServer starts a TCP server on localhost that runs handle for every
+// client, and returns its port.
+func probeServer(t *testing.T, handle func(conn net.Conn)) int {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen on port: %v\n", err)
+	}
+
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				conn.SetDeadline(time.Now().Add(5 * time.Second))
+				handle(conn)
+			}()
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// serverPort returns the port of an httptest server.
+func serverPort(t *testing.T, srv *httptest.Server) int {
+	t.Helper()
+
+	return srv.Listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestRunProbes(t *testing.T) {
+	withServer := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.25.3 (Ubuntu)")
+		fmt.Fprintln(w, "hello")
+	}
+
+	testCases := []struct {
+		name     string
+		port     func(t *testing.T) int
+		expected *scan.Service
+	}{
+		{
+			name: "HTTP",
+			port: func(t *testing.T) int {
+				srv := httptest.NewServer(http.HandlerFunc(withServer))
+				t.Cleanup(srv.Close)
+				return serverPort(t, srv)
+			},
+			expected: &scan.Service{Name: "http", Product: "nginx", Version: "1.25.3", Probe: "http"},
+		},
+		{
+			name: "HTTPS",
+			port: func(t *testing.T) int {
+				srv := httptest.NewUnstartedServer(http.HandlerFunc(withServer))
+				srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+				srv.StartTLS()
+				t.Cleanup(srv.Close)
+				return serverPort(t, srv)
+			},
+			expected: &scan.Service{Name: "https", Product: "nginx", Version: "1.25.3", Probe: "tls"},
+		},
+		{
+			name: "SSH",
+			port: func(t *testing.T) int {
+				return probeServer(t, func(conn net.Conn) {
+					io.WriteString(conn, "SSH-2.0-OpenSSH_9.3p1 Debian-1\r\n")
+					io.Copy(io.Discard, conn)
+				})
+			},
+			expected: &scan.Service{Name: "ssh", Product: "OpenSSH", Version: "9.3p1", Probe: "ssh"},
+		},
+		{
+			name: "SMTP",
+			port: func(t *testing.T) int {
+				return probeServer(t, func(conn net.Conn) {
+					io.WriteString(conn, "220 mail.example.com ESMTP Postfix\r\n")
+					io.Copy(io.Discard, conn)
+				})
+			},
+			expected: &scan.Service{Name: "smtp", Product: "Postfix", Probe: "smtp"},
+		},
+		{
+			name: "Redis",
+			port: func(t *testing.T) int {
+				return probeServer(t, func(conn net.Conn) {
+					r := bufio.NewReader(conn)
+					for {
+						line, err := r.ReadString('\n')
+						if err != nil {
+							return
+						}
+
+						switch strings.TrimSpace(line) {
+						case "PING":
+							io.WriteString(conn, "+PONG\r\n")
+						case "INFO server":
+							info := "# Server\r\nredis_version:7.2.3\r\nredis_mode:standalone\r\n"
+							fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(info), info)
+						default:
+							io.WriteString(conn, "-ERR unknown command\r\n")
+						}
+					}
+				})
+			},
+			expected: &scan.Service{Name: "redis", Product: "Redis", Version: "7.2.3", Probe: "redis"},
+		},
+		{
+			name: "PostgreSQL",
+			port: func(t *testing.T) int {
+				return probeServer(t, func(conn net.Conn) {
+					req := make([]byte, 8)
+					if _, err := io.ReadFull(conn, req); err != nil {
+						return
+					}
+
+					if binary.BigEndian.Uint32(req[4:]) == 80877103 {
+						io.WriteString(conn, "N")
+					}
+				})
+			},
+			expected: &scan.Service{Name: "postgresql", Product: "PostgreSQL", Probe: "postgresql"},
+		},
+		{
+			name: "MariaDB",
+			port: func(t *testing.T) int {
+				return probeServer(t, func(conn net.Conn) {
+					payload := append([]byte{10}, "5.5.5-10.11.4-MariaDB-1\x00rest of handshake"...)
+					header := []byte{byte(len(payload)), 0, 0, 0}
+					conn.Write(append(header, payload...))
+					io.Copy(io.Discard, conn)
+				})
+			},
+			expected: &scan.Service{Name: "mysql", Product: "MariaDB", Version: "10.11.4", Probe: "mysql"},
+		},
+		{
+			name: "MongoDB",
+			port: func(t *testing.T) int {
+				return probeServer(t, func(conn net.Conn) {
+					header := make([]byte, 16)
+					if _, err := io.ReadFull(conn, header); err != nil {
+						return
+					}
+
+					if binary.LittleEndian.Uint32(header[12:]) != 2004 {
+						return
+					}
+
+					reply := make([]byte, 16)
+					binary.LittleEndian.PutUint32(reply[0:], 16)
+					binary.LittleEndian.PutUint32(reply[8:], binary.LittleEndian.Uint32(header[4:]))
+					binary.LittleEndian.PutUint32(reply[12:], 1)
+					conn.Write(reply)
+				})
+			},
+			expected: &scan.Service{Name: "mongodb", Product: "MongoDB", Probe: "mongodb"},
+		},
+		{
+			name: "Silent",
+			port: func(t *testing.T) int {
+				return probeServer(t, func(conn net.Conn) {
+					io.Copy(io.Discard, conn)
+				})
+			},
+			expected: nil,
+		},
+	}
+
+	hl := &scan.HostsList{}
+	hl.Add("127.0.0.1")
+
+	cfg := scan.Config{Probes: scan.Probes(), ProbeTimeout: 200 * time.Millisecond}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res := scan.RunWithConfig(hl, []int{tc.port(t)}, cfg)
+
+			svc := res[0].PortStates[0].Service
+
+			if tc.expected == nil {
+				if svc != nil {
+					t.Errorf("Expected no service, got %+v instead\n", svc)
+				}
+
+				return
+			}
+
+			if svc == nil {
+				t.Fatalf("Expected service %+v, got nil instead\n", tc.expected)
+			}
+
+			if *svc != *tc.expected {
+				t.Errorf("Expected service %+v, got %+v instead\n", tc.expected, svc)
+			}
+		})
+	}
+}
+
+func TestRegisterProbe(t *testing.T) {
+	echo := scan.NewProbe("echo-test", []int{7}, func(ctx context.Context, conn net.Conn) (*scan.Service, error) {
+		if _, err := io.WriteString(conn, "ping\n"); err != nil {
+			return nil, err
+		}
+
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil || line != "ping\n" {
+			return nil, err
+		}
+
+		return &scan.Service{Name: "echo"}, nil
+	})
+
+	scan.RegisterProbe(echo)
+
+	if scan.LookupProbe("echo-test") == nil {
+		t.Fatal("Expected probe echo-test to be registered")
+	}
+
+	n := scantest.NewNetwork()
+	n.AddHost("echo.test").TCP(7, scan.StateOpen).Serve(func(conn net.Conn) {
+		io.Copy(conn, conn)
+	})
+
+	hl := &scan.HostsList{}
+	hl.Add("echo.test")
+
+	res := scan.RunWithConfig(hl, []int{7}, scan.Config{Probes: []scan.Probe{echo}, Dialer: n, Resolver: n})
+
+	svc := res[0].PortStates[0].Service
+
+	if svc == nil || svc.Name != "echo" || svc.Probe != "echo-test" {
+		t.Errorf("Expected echo service, got %+v instead\n", svc)
+	}
+}
+
+func TestIdentifyOrder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cfg      scan.Config
+		serve    func(p *scantest.Port)
+		expected *scan.Service
+		dials    int
+		maxTime  time.Duration
+	}{
+		{
+			// The ssh probe goes first, so the silent tls and http probes
+			// are not tried on the unusual port.
+			name: "Banner",
+			cfg:  scan.Config{Banners: true},
+			serve: func(p *scantest.Port) {
+				p.WithBanner("SSH-2.0-OpenSSH_9.3p1\r\n")
+			},
+			expected: &scan.Service{Name: "ssh", Product: "OpenSSH", Version: "9.3p1", Probe: "ssh"},
+			dials:    2,
+			maxTime:  time.Second,
+		},
+		{
+			name: "Deadline",
+			cfg:  scan.Config{IdentifyTimeout: 300 * time.Millisecond},
+			serve: func(p *scantest.Port) {
+				p.Serve(func(conn net.Conn) { io.Copy(io.Discard, conn) })
+			},
+			maxTime: 800 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			n := scantest.NewNetwork()
+			tc.serve(n.AddHost("10.0.0.1").TCP(9999, scan.StateOpen))
+
+			cfg := tc.cfg
+			cfg.Probes = scan.Probes()
+			cfg.ProbeTimeout = 200 * time.Millisecond
+
+			s := scan.NewScanner(scan.WithConfig(cfg), scan.WithDialer(n), scan.WithResolver(n))
+
+			start := time.Now()
+
+			res, err := s.ScanHost(context.Background(), "10.0.0.1", []int{9999})
+			if err != nil {
+				t.Fatalf("Expected no error, got %q\n", err)
+			}
+
+			if elapsed := time.Since(start); elapsed > tc.maxTime {
+				t.Errorf("Expected identification within %s, took %s\n", tc.maxTime, elapsed)
+			}
+
+			svc := res.PortStates[0].Service
+
+			switch {
+			case tc.expected == nil && svc != nil:
+				t.Errorf("Expected no service, got %+v instead\n", svc)
+			case tc.expected != nil && (svc == nil || *svc != *tc.expected):
+				t.Errorf("Expected service %+v, got %+v instead\n", tc.expected, svc)
+			}
+
+			if tc.dials > 0 && len(n.Dials()) != tc.dials {
+				t.Errorf("Expected %d dials, got %q instead\n", tc.dials, n.Dials())
+			}
+		})
+	}
+}
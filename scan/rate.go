@@ -0,0 +1,216 @@
+package scan
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// NetworkLimit caps how hard the hosts of a network are scanned, all hosts
+// together, to spare fragile networks and appliances or stay below the
+// thresholds of intrusion detection systems.
+type NetworkLimit struct {
+	// Prefix is the network the limit applies to. Hosts are in it when any
+	// of their addresses is.
+	Prefix netip.Prefix
+	// Rate is the maximum number of ports scanned per second in the network.
+	// 0 means no limit.
+	Rate int
+	// Burst is how many ports can be scanned at once, beyond the Rate, after
+	// the network was left alone for a while. 0 means 1, so scans are evenly
+	// spaced.
+	Burst int
+	// MaxConcurrent is the maximum number of ports scanned at the same time
+	// in the network. 0 means no limit.
+	MaxConcurrent int
+}
+
+// limiter is a token bucket. It holds up to burst tokens, refilled at rate
+// tokens per second, and each scan takes one.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newLimiter returns a limiter for rate scans per second, with bursts of up
+// to burst scans, or nil, which never waits, when rate is lower than 1.
+func newLimiter(rate, burst int) *limiter {
+	if rate < 1 {
+		return nil
+	}
+
+	burst = max(burst, 1)
+
+	return &limiter{rate: float64(rate), burst: float64(burst), tokens: float64(burst)}
+}
+
+// wait takes a token, blocking until one is available or until ctx is done.
+// The token is taken even when ctx is done first.
+func (l *limiter) wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+
+	l.last = now
+
+	// Tokens go below zero while scans wait for them, so each one waits for
+	// its own token.
+	l.tokens--
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttle limits the rate of the scans and how many run at the same time,
+// for the whole scan, a host or a network. A nil throttle never blocks.
+type throttle struct {
+	rate  *limiter
+	slots chan struct{}
+}
+
+// newThrottle returns a throttle for rate scans per second, with bursts of
+// up to burst scans, and concurrent scans at the same time. It returns nil
+// when rate and concurrent are both lower than 1.
+func newThrottle(rate, burst, concurrent int) *throttle {
+	if rate < 1 && concurrent < 1 {
+		return nil
+	}
+
+	t := &throttle{rate: newLimiter(rate, burst)}
+	if concurrent > 0 {
+		t.slots = make(chan struct{}, concurrent)
+	}
+
+	return t
+}
+
+// acquire waits for a free slot. Scans call release once done, unless
+// acquire fails because ctx is done.
+func (t *throttle) acquire(ctx context.Context) error {
+	if t == nil || t.slots == nil {
+		return ctx.Err()
+	}
+
+	select {
+	case t.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// wait waits for the rate, once the scan holds its slot.
+func (t *throttle) wait(ctx context.Context) error {
+	if t == nil {
+		return ctx.Err()
+	}
+
+	return t.rate.wait(ctx)
+}
+
+// release frees the slot taken by acquire.
+func (t *throttle) release() {
+	if t == nil || t.slots == nil {
+		return
+	}
+
+	<-t.slots
+}
+
+// acquireAll acquires the slots of the throttles in order, then waits for
+// their rates, and returns the function releasing the slots. It fails right
+// away when ctx is done. Rates come last, right before the scan, so no
+// token is spent while waiting for a slot. Throttles shared between hosts
+// must always come in the same order, so scans never wait on each other's
+// slots.
+func acquireAll(ctx context.Context, throttles []*throttle) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, t := range throttles {
+		if err := t.acquire(ctx); err != nil {
+			releaseAll(throttles[:i])
+			return nil, err
+		}
+	}
+
+	for _, t := range throttles {
+		if err := t.wait(ctx); err != nil {
+			releaseAll(throttles)
+			return nil, err
+		}
+	}
+
+	return func() { releaseAll(throttles) }, nil
+}
+
+// releaseAll releases the throttles.
+func releaseAll(throttles []*throttle) {
+	for _, t := range throttles {
+		t.release()
+	}
+}
+
+// hostThrottles returns the throttles for a host with the addresses addrs:
+// the one of the whole scan, those of the networks the host is in, in the
+// order of cfg.NetworkLimits, and the one of the host. Throttles that never
+// block are left out.
+func hostThrottles(global *throttle, networks []*throttle, addrs []string, cfg Config) []*throttle {
+	var throttles []*throttle
+
+	if global != nil {
+		throttles = append(throttles, global)
+	}
+
+	for i, nl := range cfg.NetworkLimits {
+		if networks[i] != nil && inPrefix(nl.Prefix, addrs) {
+			throttles = append(throttles, networks[i])
+		}
+	}
+
+	if t := newThrottle(cfg.HostRate, 1, cfg.MaxPerHost); t != nil {
+		throttles = append(throttles, t)
+	}
+
+	return throttles
+}
+
+// inPrefix reports whether any of the addresses is in prefix.
+func inPrefix(prefix netip.Prefix, addrs []string) bool {
+	for _, a := range addrs {
+		addr, err := netip.ParseAddr(a)
+		if err != nil {
+			continue
+		}
+
+		if prefix.Contains(addr.Unmap().WithZone("")) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,48 +1,198 @@
 package scan
 
 import (
+	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"net"
+	"syscall"
 	"time"
 )
 
+// DefaultConcurrency is the number of ports scanned at the same time when
+// the configuration does not set one.
+const DefaultConcurrency = 100
+
+// DefaultTimeout is how long connecting to a port can take when the
+// configuration does not set it.
+const DefaultTimeout = 1 * time.Second
+
 // Define a new custom type PortState that represents the state for
 // single TCP port.
 type PortState struct {
-	Port int
-	Open state
+	Port int `json:"port"`
+	// Protocol is the port protocol, ProtocolTCP or ProtocolUDP.
+	Protocol string `json:"protocol"`
+	// Address is the address of the host the port was scanned on, one of
+	// Results.Addrs.
+	Address string `json:"address,omitempty"`
+	State   State  `json:"state"`
+	// Reason is a short description of the response that determined State,
+	// such as "syn-ack" or "conn-refused".
+	Reason string `json:"reason"`
+	// Err is the error returned when connecting to the port, if any.
+	Err error `json:"-"`
+	// Latency is the time it took to get the response.
+	Latency time.Duration `json:"latency_ns"`
+	// Banner is what the server sent right after connecting, with
+	// non-printable bytes escaped. Only set when Config.Banners is true.
+	Banner string `json:"banner,omitempty"`
+	// Service is the service identified on the port by one of the probes
+	// in Config.Probes, if any.
+	Service *Service `json:"service,omitempty"`
+	// TLS describes the TLS session and certificate found on the port.
+	// Only set when Config.TLS is true and the port speaks TLS.
+	TLS *TLSInfo `json:"tls,omitempty"`
+	// HTTP describes the response to a request for the root page. Only set
+	// when Config.HTTP is true and the port serves HTTP.
+	HTTP *HTTPInfo `json:"http,omitempty"`
 }
 
-// Define custom type `state` as a wrapper for the `bool` type.
-type state bool
+// State represents the state of a port as seen by the scanner.
+type State int
+
+const (
+	// StateClosed means the host actively refused the connection.
+	StateClosed State = iota
+	// StateOpen means the connection was accepted.
+	StateOpen
+	// StateFiltered means no answer was received, usually because a
+	// firewall dropped the packets.
+	StateFiltered
+	// StateUnreachable means the network reported the host or network as
+	// unreachable.
+	StateUnreachable
+	// StateOpenFiltered means a UDP port did not answer, so it is either
+	// open with a silent service, or filtered.
+	StateOpenFiltered
+)
+
+// Reasons reported in PortState.
+const (
+	ReasonSynAck          = "syn-ack"
+	ReasonConnRefused     = "conn-refused"
+	ReasonReset           = "reset"
+	ReasonNoResponse      = "no-response"
+	ReasonHostUnreach     = "host-unreach"
+	ReasonNetUnreach      = "net-unreach"
+	ReasonAdminProhibited = "admin-prohibited"
+	ReasonError           = "error"
+)
 
-// String converts the boolean value of the state type to human-readable string.
+// String converts the state to a human-readable string.
 // Using the Stringer interface to implement the String method.
-func (s state) String() string {
-	if s {
+func (s State) String() string {
+	switch s {
+	case StateOpen:
 		return "open"
+	case StateClosed:
+		return "closed"
+	case StateFiltered:
+		return "filtered"
+	case StateUnreachable:
+		return "unreachable"
+	case StateOpenFiltered:
+		return "open|filtered"
 	}
-	return "closed"
+
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// MarshalText encodes the state as its string, so it is readable in
+// formats such as JSON.
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
 }
 
-// scanPort perfoms a TCP scan on a single port.
-func scanPort(host string, port int) PortState {
-	p := PortState{Port: port}
+// UnmarshalText decodes a state encoded by MarshalText.
+func (s *State) UnmarshalText(text []byte) error {
+	for _, st := range []State{StateOpen, StateClosed, StateFiltered, StateUnreachable, StateOpenFiltered} {
+		if st.String() == string(text) {
+			*s = st
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown port state %q", text)
+}
+
+// classify determines the port state and reason from the error returned
+// by a connection attempt.
+func classify(err error) (State, string) {
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return StateOpen, ReasonSynAck
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return StateClosed, ReasonConnRefused
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return StateUnreachable, ReasonHostUnreach
+	case errors.Is(err, syscall.ENETUNREACH):
+		return StateUnreachable, ReasonNetUnreach
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+		return StateFiltered, ReasonAdminProhibited
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return StateFiltered, ReasonNoResponse
+	}
+
+	return StateFiltered, ReasonError
+}
+
+// scanPort perfoms a TCP scan on a single port of host, at the address addr.
+// It returns ctx.Err() when the scan was interrupted before the port state
+// could be determined.
+func scanPort(ctx context.Context, host, addr string, port int, cfg Config) (PortState, error) {
+	p := PortState{Port: port, Protocol: ProtocolTCP, Address: addr}
+
+	address := net.JoinHostPort(addr, fmt.Sprintf("%d", port))
+
+	start := time.Now()
+	scanConn, err := cfg.dial(ctx, "tcp", address, cfg.timeout())
+	p.Latency = time.Since(start)
 
-	address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
+	// Interrupted dials do not tell anything about the port.
+	if err != nil && ctx.Err() != nil {
+		return p, ctx.Err()
+	}
+
+	p.State, p.Reason = classify(err)
+	p.Err = err
+
+	// Close the connection if it was successful, after reading the banner
+	// when requested.
+	if err == nil {
+		if cfg.Banners {
+			p.Banner = grabBanner(scanConn, cfg.bannerSize(), cfg.bannerTimeout())
+		}
+
+		scanConn.Close()
 
-	scanConn, err := net.DialTimeout("tcp", address, 1*time.Second)
-	// Verify the function returned an error. If so, assume the port is closed.
-	// This is a naive approach, but it works for our purposes.
-	if err != nil {
-		return p
+		inspectOpenPort(ctx, host, &p, cfg)
 	}
 
-	// Close the connection if it was successful. Set the property to true.
-	scanConn.Close()
-	p.Open = true
+	return p, nil
+}
+
+// inspectOpenPort identifies the service on the open port and inspects its
+// TLS session and web server, as enabled in cfg. Each of them makes its own
+// connections to the port, at p.Address, while the TLS server name and the
+// HTTP Host header use the name of the host.
+func inspectOpenPort(ctx context.Context, host string, p *PortState, cfg Config) {
+	address := net.JoinHostPort(p.Address, fmt.Sprintf("%d", p.Port))
+
+	if len(cfg.Probes) > 0 {
+		p.Service = identify(ctx, address, p, cfg)
+	}
+
+	if cfg.TLS || cfg.CheckTLS {
+		p.TLS = inspectTLS(ctx, host, address, p.Port, p.Service, cfg)
+	}
 
-	return p
+	if cfg.HTTP {
+		p.HTTP = enumerateHTTP(ctx, host, address, p.Port, p.Service, cfg)
+	}
 }
 
 // The scanPort function is private. We do not want users to call it directly.
@@ -51,33 +201,250 @@ func scanPort(host string, port int) PortState {
 
 // Results represents the results of a port scan for a single host.
 type Results struct {
-	Host       string
-	NotFound   bool
-	PortStates []PortState
+	Host     string `json:"host"`
+	NotFound bool   `json:"not_found"`
+	// Addrs lists the addresses the host resolved to, of the IP version
+	// selected by Config.IPVersion.
+	Addrs      []string    `json:"addresses,omitempty"`
+	PortStates []PortState `json:"ports"`
+	// Findings lists the problems found on the ports by the checks enabled
+	// in Config, such as CheckTLS.
+	Findings []Finding `json:"findings,omitempty"`
+	// Timing describes the timeout chosen for the host. Only set when
+	// Config.Adaptive is true.
+	Timing *Timing `json:"timing,omitempty"`
 }
 
-// Run perfoms a TCP scan on the hosts list
-func Run(hl *HostsList, ports []int) []Results {
-	res := make([]Results, 0, len(hl.Hosts))
+// Config defines how Run scans the hosts list.
+type Config struct {
+	// Technique is how TCP ports are scanned, TechniqueConnect or
+	// TechniqueSYN. Empty uses TechniqueConnect.
+	Technique string
+	// IPVersion restricts the addresses of the hosts to IPv4 when 4, or to
+	// IPv6 when 6. Hosts without an address of that version are not
+	// found. 0 keeps both.
+	IPVersion int
+	// FirstAddress scans only the first address of each host, as sorted by
+	// the Resolver, instead of all of them.
+	FirstAddress bool
+	// Dialer opens all the connections to the scanned ports, except for the
+	// SYN packets of TechniqueSYN. When nil, a net.Dialer is used.
+	Dialer Dialer
+	// Resolver looks up the hosts. When nil, net.DefaultResolver is used.
+	Resolver Resolver
+	// UDPPorts are the UDP ports scanned on each host, after the TCP ports.
+	UDPPorts []int
+	// UDPTimeout is how long to wait for an answer from each UDP port.
+	// Values lower than 1 use DefaultUDPTimeout.
+	UDPTimeout time.Duration
+	// Timeout is how long connecting to each port can take, or waiting for
+	// the reply to a SYN packet. Values lower than 1 use DefaultTimeout.
+	Timeout time.Duration
+	// Adaptive tunes the timeout of each host from the round-trip times of
+	// its TCP ports that answered, starting at Timeout and never going
+	// above it, nor below MinAdaptiveTimeout.
+	Adaptive bool
+	// Retries is how many more times ports that did not answer are scanned,
+	// in case the packets were lost.
+	Retries int
+	// Rate is the maximum number of ports scanned per second, across all
+	// hosts, including the retries. Values lower than 1 mean no limit.
+	Rate int
+	// HostRate is the maximum number of ports scanned per second on each
+	// host. Values lower than 1 mean no limit.
+	HostRate int
+	// MaxPerHost is the maximum number of ports scanned at the same time on
+	// each host. Values lower than 1 mean no limit other than Concurrency.
+	MaxPerHost int
+	// NetworkLimits cap the scans of the hosts in some networks, on top of
+	// the other limits. Hosts in several of the networks get all of their
+	// limits.
+	NetworkLimits []NetworkLimit
+	// Concurrency is the maximum number of ports scanned at the same time,
+	// across all hosts. Values lower than 1 use DefaultConcurrency.
+	Concurrency int
+	// Banners enables reading the first bytes sent by the server on open
+	// ports, stored in PortState.Banner.
+	Banners bool
+	// BannerSize is the maximum number of bytes read for a banner. Values
+	// lower than 1 use DefaultBannerSize.
+	BannerSize int
+	// BannerTimeout is how long to wait for a banner. Values lower than 1
+	// use DefaultBannerTimeout.
+	BannerTimeout time.Duration
+	// Probes are run in turn against each open port, until one of them
+	// identifies the service. With Banners, the probes matching the banner
+	// go first. See Probes for the built-in ones.
+	Probes []Probe
+	// ProbeTimeout bounds each probe, including its connection. Values
+	// lower than 1 use DefaultProbeTimeout.
+	ProbeTimeout time.Duration
+	// IdentifyTimeout bounds the identification of each open port, all
+	// probes included. Values lower than 1 use DefaultIdentifyTimeout.
+	IdentifyTimeout time.Duration
+	// TLS enables the TLS handshake on open ports, using STARTTLS for the
+	// SMTP, IMAP, POP3, FTP and PostgreSQL services, stored in
+	// PortState.TLS.
+	TLS bool
+	// TLSTimeout bounds each TLS handshake, including its connection.
+	// Values lower than 1 use DefaultTLSTimeout.
+	TLSTimeout time.Duration
+	// RootCAs are the certificate authorities trusted to verify the TLS
+	// certificates. When nil, the system pool is used.
+	RootCAs *x509.CertPool
+	// CheckTLS enables the tls check, reported in Results.Findings: expired,
+	// expiring, self-signed, untrusted and mismatched certificates, and
+	// servers accepting TLS 1.0, TLS 1.1 or weak cipher suites. It implies
+	// TLS.
+	CheckTLS bool
+	// CertExpiryDays is how many days before their expiry certificates are
+	// reported by the tls check. Values lower than 1 use
+	// DefaultCertExpiryDays.
+	CertExpiryDays int
+	// HTTP enables requesting the root page of web servers, stored in
+	// PortState.HTTP. Web servers are found by the probes, or by the port
+	// number when Probes is empty.
+	HTTP bool
+	// HTTPMethod is the request method, GET or HEAD. Empty uses
+	// DefaultHTTPMethod.
+	HTTPMethod string
+	// HTTPTimeout bounds each request, including its connection. Values
+	// lower than 1 use DefaultHTTPTimeout.
+	HTTPTimeout time.Duration
+}
 
-	for _, host := range hl.Hosts {
-		r := Results{Host: host}
+// timeout returns the timeout of the connections to the ports.
+func (c Config) timeout() time.Duration {
+	if c.Timeout < 1 {
+		return DefaultTimeout
+	}
 
-		// Use ne.LookupHost to resolve the hostname to an IP address.
-		// If the host is not found, set the NotFound property to true.
-		if _, err := net.LookupHost(host); err != nil {
-			r.NotFound = true
-			res = append(res, r)
-			continue
-		}
+	return c.Timeout
+}
 
-		// If host was found, loop through the ports and call scanPort for each port.
-		for _, port := range ports {
-			r.PortStates = append(r.PortStates, scanPort(host, port))
-		}
+// workers returns the number of workers to start for the configuration.
+func (c Config) workers() int {
+	if c.Concurrency < 1 {
+		return DefaultConcurrency
+	}
+
+	return c.Concurrency
+}
 
-		res = append(res, r)
+// bannerSize returns the maximum banner size for the configuration.
+func (c Config) bannerSize() int {
+	if c.BannerSize < 1 {
+		return DefaultBannerSize
 	}
 
+	return c.BannerSize
+}
+
+// bannerTimeout returns the banner read timeout for the configuration.
+func (c Config) bannerTimeout() time.Duration {
+	if c.BannerTimeout < 1 {
+		return DefaultBannerTimeout
+	}
+
+	return c.BannerTimeout
+}
+
+// probeTimeout returns the timeout for each probe.
+func (c Config) probeTimeout() time.Duration {
+	if c.ProbeTimeout < 1 {
+		return DefaultProbeTimeout
+	}
+
+	return c.ProbeTimeout
+}
+
+// identifyTimeout returns the timeout for identifying the service of each
+// port.
+func (c Config) identifyTimeout() time.Duration {
+	if c.IdentifyTimeout < 1 {
+		return DefaultIdentifyTimeout
+	}
+
+	return c.IdentifyTimeout
+}
+
+// tlsTimeout returns the timeout for each TLS handshake.
+func (c Config) tlsTimeout() time.Duration {
+	if c.TLSTimeout < 1 {
+		return DefaultTLSTimeout
+	}
+
+	return c.TLSTimeout
+}
+
+// certExpiryDays returns how many days before their expiry certificates are
+// reported.
+func (c Config) certExpiryDays() int {
+	if c.CertExpiryDays < 1 {
+		return DefaultCertExpiryDays
+	}
+
+	return c.CertExpiryDays
+}
+
+// httpMethod returns the method of the HTTP requests.
+func (c Config) httpMethod() string {
+	if c.HTTPMethod == "" {
+		return DefaultHTTPMethod
+	}
+
+	return c.HTTPMethod
+}
+
+// httpTimeout returns the timeout for each HTTP request.
+func (c Config) httpTimeout() time.Duration {
+	if c.HTTPTimeout < 1 {
+		return DefaultHTTPTimeout
+	}
+
+	return c.HTTPTimeout
+}
+
+// udpTimeout returns how long to wait for UDP answers.
+func (c Config) udpTimeout() time.Duration {
+	if c.UDPTimeout < 1 {
+		return DefaultUDPTimeout
+	}
+
+	return c.UDPTimeout
+}
+
+// Run perfoms a TCP scan on the hosts list using the default configuration.
+func Run(hl *HostsList, ports []int) []Results {
+	return RunWithConfig(hl, ports, Config{})
+}
+
+// RunWithConfig perfoms a TCP scan on the hosts list using a bounded pool of
+// workers, and a UDP scan when cfg.UDPPorts is set. CIDR blocks and ranges
+// in the list are expanded, with one Results per address. Results are
+// returned in the same order as the hosts in the list, and the PortStates
+// of each host follow the order of ports, then cfg.UDPPorts.
+func RunWithConfig(hl *HostsList, ports []int, cfg Config) []Results {
+	res, _ := RunContext(context.Background(), hl, ports, cfg)
 	return res
 }
+
+// RunContext works like RunWithConfig, but stops scanning when ctx is done.
+// In that case, it returns the results collected so far along with
+// ctx.Err(). Hosts that were not resolved and ports that were not scanned
+// before the interruption are left out of the results.
+//
+// With TechniqueSYN, it returns an error wrapping ErrSYNUnavailable, and no
+// results, when SYN scans are not possible on this system.
+func RunContext(ctx context.Context, hl *HostsList, ports []int, cfg Config) ([]Results, error) {
+	return RunFunc(ctx, hl, ports, cfg, nil)
+}
+
+// RunFunc works like RunContext, and also calls fn with the Results of each
+// host as soon as it is scanned, so they can be shown or saved while the
+// scan goes on. Hosts come in the order they finish, and fn is never called
+// concurrently. When ctx is done, fn gets the partial results of the hosts
+// interrupted, once the workers stopped. fn can be nil.
+func RunFunc(ctx context.Context, hl *HostsList, ports []int, cfg Config, fn func(Results)) ([]Results, error) {
+	return NewScanner(WithConfig(cfg)).scanHosts(ctx, hl.Hosts, ports, fn)
+}
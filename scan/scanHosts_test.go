@@ -1,71 +1,66 @@
 package scan_test
 
 import (
-	"net"
+	"context"
+	"errors"
 	"strconv"
 	"testing"
 
 	"github.com/Dbaker1298/pScan/scan"
+	"github.com/Dbaker1298/pScan/scan/scantest"
 )
 
 func TestStateString(t *testing.T) {
 	ps := scan.PortState{}
 
-	if ps.Open.String() != "closed" {
-		t.Errorf("Expected %q, got %q instead\n", "closed", ps.Open.String())
+	if ps.State.String() != "closed" {
+		t.Errorf("Expected %q, got %q instead\n", "closed", ps.State.String())
 	}
 
-	ps.Open = true
+	testCases := []struct {
+		state    scan.State
+		expected string
+	}{
+		{scan.StateOpen, "open"},
+		{scan.StateClosed, "closed"},
+		{scan.StateFiltered, "filtered"},
+		{scan.StateUnreachable, "unreachable"},
+		{scan.StateOpenFiltered, "open|filtered"},
+	}
 
-	if ps.Open.String() != "open" {
-		t.Errorf("Expected %q, got %q instead\n", "open", ps.Open.String())
+	for _, tc := range testCases {
+		if tc.state.String() != tc.expected {
+			t.Errorf("Expected %q, got %q instead\n", tc.expected, tc.state.String())
+		}
 	}
 }
 
 func TestRunHostFound(t *testing.T) {
 	testCases := []struct {
-		name          string
-		expectedState string
+		name           string
+		port           int
+		expectedState  string
+		expectedReason string
 	}{
-		{"OpenPort", "open"},
-		{"ClosedPort", "closed"},
+		{"OpenPort", 22, "open", scan.ReasonSynAck},
+		{"ClosedPort", 23, "closed", scan.ReasonConnRefused},
 	}
 
-	// Testing against localhost
-	host := "localhost"
+	host := "web.test"
 	hl := &scan.HostsList{}
 
 	hl.Add(host)
 
-	ports := []int{}
+	// Ports that are not declared are closed.
+	n := scantest.NewNetwork()
+	n.AddHost(host).TCP(22, scan.StateOpen)
 
-	// Init ports, 1 open, 1 closed
+	ports := []int{}
 	for _, tc := range testCases {
-		ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
-		if err != nil {
-			t.Fatalf("Failed to listen on port: %v\n", err)
-		}
-
-		defer ln.Close()
-
-		_, portStr, err := net.SplitHostPort(ln.Addr().String())
-		if err != nil {
-			t.Fatalf("Failed to split host and port: %v\n", err)
-		}
-
-		port, err := strconv.Atoi(portStr)
-		if err != nil {
-			t.Fatalf("Failed to convert port string to int: %v\n", err)
-		}
-
-		ports = append(ports, port)
-
-		if tc.name == "ClosedPort" {
-			ln.Close()
-		}
+		ports = append(ports, tc.port)
 	}
 
-	res := scan.Run(hl, ports)
+	res := scan.RunWithConfig(hl, ports, scan.Config{Dialer: n, Resolver: n})
 
 	// Verify the results of HostFound test
 	if len(res) != 1 {
@@ -85,24 +80,30 @@ func TestRunHostFound(t *testing.T) {
 	}
 
 	for i, tc := range testCases {
-		if res[0].PortStates[i].Port != ports[i] {
-			t.Errorf("Expected port %d, got %d instead\n", ports[0], res[0].PortStates[i].Port)
+		if res[0].PortStates[i].Port != tc.port {
+			t.Errorf("Expected port %d, got %d instead\n", tc.port, res[0].PortStates[i].Port)
 		}
 
-		if res[0].PortStates[i].Open.String() != tc.expectedState {
-			t.Errorf("Expected port %d to be %s\n", ports[i], tc.expectedState)
+		if res[0].PortStates[i].State.String() != tc.expectedState {
+			t.Errorf("Expected port %d to be %s\n", tc.port, tc.expectedState)
+		}
+
+		if res[0].PortStates[i].Reason != tc.expectedReason {
+			t.Errorf("Expected port %d reason %q, got %q instead\n", tc.port, tc.expectedReason, res[0].PortStates[i].Reason)
 		}
 	}
 }
 
 // Test when the host is not found
 func TestRunHostNotFound(t *testing.T) {
-	host := "389.389.389.389"
+	host := "unknown.test"
 	hl := &scan.HostsList{}
 
 	hl.Add(host)
 
-	res := scan.Run(hl, []int{})
+	n := scantest.NewNetwork()
+
+	res := scan.RunWithConfig(hl, []int{}, scan.Config{Dialer: n, Resolver: n})
 
 	// Verify the results of HostNotFound test
 	if len(res) != 1 {
@@ -121,3 +122,166 @@ func TestRunHostNotFound(t *testing.T) {
 		t.Errorf("Expected 0 port states, got %d instead\n", len(res[0].PortStates))
 	}
 }
+
+// Test that results keep the hosts and ports order regardless of the number
+// of workers scanning them.
+func TestRunWithConfigOrder(t *testing.T) {
+	hosts := []string{"web.test", "unknown.test", "10.0.0.1"}
+	hl := &scan.HostsList{Hosts: hosts}
+
+	n := scantest.NewNetwork()
+	web := n.AddHost("web.test")
+	addr := n.AddHost("10.0.0.1")
+
+	ports := []int{}
+	open := map[int]bool{}
+
+	// Init ports, alternating open and closed
+	for port := 1; port <= 10; port++ {
+		ports = append(ports, port)
+		open[port] = port%2 == 1
+
+		if open[port] {
+			web.TCP(port, scan.StateOpen)
+			addr.TCP(port, scan.StateOpen)
+		}
+	}
+
+	for _, concurrency := range []int{1, 3, 50} {
+		t.Run(strconv.Itoa(concurrency), func(t *testing.T) {
+			res := scan.RunWithConfig(hl, ports, scan.Config{Concurrency: concurrency, Dialer: n, Resolver: n})
+
+			if len(res) != len(hosts) {
+				t.Fatalf("Expected %d results, got %d instead\n", len(hosts), len(res))
+			}
+
+			for i, r := range res {
+				if r.Host != hosts[i] {
+					t.Errorf("Expected host %q at index %d, got %q instead\n", hosts[i], i, r.Host)
+				}
+
+				if r.NotFound {
+					continue
+				}
+
+				if len(r.PortStates) != len(ports) {
+					t.Fatalf("Expected %d port states, got %d instead\n", len(ports), len(r.PortStates))
+				}
+
+				for j, ps := range r.PortStates {
+					if ps.Port != ports[j] {
+						t.Errorf("Expected port %d at index %d, got %d instead\n", ports[j], j, ps.Port)
+					}
+
+					if (ps.State == scan.StateOpen) != open[ps.Port] {
+						t.Errorf("Expected port %d open to be %t\n", ps.Port, open[ps.Port])
+					}
+				}
+			}
+
+			if !res[1].NotFound {
+				t.Errorf("Expected host %q to NOT be found, but it was\n", hosts[1])
+			}
+		})
+	}
+}
+
+// Test that a canceled scan stops and reports the context error
+func TestRunContextCanceled(t *testing.T) {
+	hl := &scan.HostsList{}
+
+	hl.Add("web.test")
+
+	n := scantest.NewNetwork()
+	n.AddHost("web.test").TCP(22, scan.StateOpen)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res, err := scan.RunContext(ctx, hl, []int{22, 80, 443}, scan.Config{Dialer: n, Resolver: n})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected error %q, got %v instead\n", context.Canceled, err)
+	}
+
+	for _, r := range res {
+		if len(r.PortStates) != 0 {
+			t.Errorf("Expected no port scanned for host %q, got %d\n", r.Host, len(r.PortStates))
+		}
+	}
+}
+
+// Test that an unknown scan technique is refused before scanning
+func TestRunContextUnknownTechnique(t *testing.T) {
+	hl := &scan.HostsList{}
+
+	hl.Add("web.test")
+
+	n := scantest.NewNetwork()
+
+	res, err := scan.RunContext(context.Background(), hl, []int{22}, scan.Config{Technique: "fin", Dialer: n, Resolver: n})
+
+	if err == nil || errors.Is(err, scan.ErrSYNUnavailable) {
+		t.Fatalf("Expected unknown technique error, got %v instead\n", err)
+	}
+
+	if res != nil {
+		t.Errorf("Expected no results, got %v instead\n", res)
+	}
+}
+
+// Test that each host is passed to the function once, when it is done
+func TestRunFunc(t *testing.T) {
+	n := scantest.NewNetwork()
+	n.AddHost("web.test").TCP(80, scan.StateOpen)
+
+	// Add rejects the invalid range, so it can only come from a hosts file.
+	hl := &scan.HostsList{Hosts: []string{"web.test", "10.0.0.9-1"}}
+
+	var done []scan.Results
+
+	res, err := scan.RunFunc(context.Background(), hl, []int{80}, scan.Config{Dialer: n, Resolver: n}, func(r scan.Results) {
+		done = append(done, r)
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+
+	if len(res) != 2 || len(done) != len(res) {
+		t.Fatalf("Expected each of the 2 hosts once, got %v instead\n", done)
+	}
+
+	for _, r := range done {
+		switch {
+		case r.Host == "web.test" && len(r.PortStates) == 1 && r.PortStates[0].State == scan.StateOpen:
+		case r.Host == "10.0.0.9-1" && r.NotFound:
+		default:
+			t.Errorf("Unexpected results %+v\n", r)
+		}
+	}
+}
+
+// Test that ranges in the hosts list are reported per address
+func TestRunRange(t *testing.T) {
+	hl := &scan.HostsList{}
+
+	if err := hl.Add("127.0.0.1-3"); err != nil {
+		t.Fatalf("failed to initialize list: %v", err)
+	}
+
+	n := scantest.NewNetwork()
+
+	res := scan.RunWithConfig(hl, []int{}, scan.Config{Dialer: n, Resolver: n})
+
+	expected := []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}
+
+	if len(res) != len(expected) {
+		t.Fatalf("Expected %d results, got %d instead\n", len(expected), len(res))
+	}
+
+	for i, r := range res {
+		if r.Host != expected[i] {
+			t.Errorf("Expected host %q, got %q instead\n", expected[i], r.Host)
+		}
+	}
+}
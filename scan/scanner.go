@@ -0,0 +1,553 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Scanner scans hosts with a fixed configuration. It can be reused for
+// several scans, including concurrent ones.
+type Scanner struct {
+	cfg Config
+}
+
+// Option configures a Scanner.
+type Option func(*Scanner)
+
+// NewScanner returns a Scanner with the default configuration, changed by
+// the options in order.
+func NewScanner(opts ...Option) *Scanner {
+	s := &Scanner{}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// WithConfig replaces the whole configuration of the Scanner with cfg.
+// Options after it change cfg.
+func WithConfig(cfg Config) Option {
+	return func(s *Scanner) { s.cfg = cfg }
+}
+
+// WithTimeout sets how long connecting to each port can take. See
+// Config.Timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Scanner) { s.cfg.Timeout = d }
+}
+
+// WithConcurrency sets the maximum number of ports scanned at the same
+// time. See Config.Concurrency.
+func WithConcurrency(n int) Option {
+	return func(s *Scanner) { s.cfg.Concurrency = n }
+}
+
+// WithAdaptiveTimeout tunes the timeout of each host from its round-trip
+// times. See Config.Adaptive.
+func WithAdaptiveTimeout() Option {
+	return func(s *Scanner) { s.cfg.Adaptive = true }
+}
+
+// WithRetries sets how many more times ports that did not answer are
+// scanned. See Config.Retries.
+func WithRetries(n int) Option {
+	return func(s *Scanner) { s.cfg.Retries = n }
+}
+
+// WithResolver sets the Resolver looking up the hosts.
+func WithResolver(r Resolver) Option {
+	return func(s *Scanner) { s.cfg.Resolver = r }
+}
+
+// WithDialer sets the Dialer opening the connections to the ports.
+func WithDialer(d Dialer) Option {
+	return func(s *Scanner) { s.cfg.Dialer = d }
+}
+
+// WithProbes sets the probes identifying the services on open ports. See
+// Probes for the built-in ones.
+func WithProbes(probes ...Probe) Option {
+	return func(s *Scanner) { s.cfg.Probes = probes }
+}
+
+// WithRateLimit sets the maximum number of ports scanned per second. See
+// Config.Rate.
+func WithRateLimit(perSecond int) Option {
+	return func(s *Scanner) { s.cfg.Rate = perSecond }
+}
+
+// WithHostRateLimit sets the maximum number of ports scanned per second on
+// each host. See Config.HostRate.
+func WithHostRateLimit(perSecond int) Option {
+	return func(s *Scanner) { s.cfg.HostRate = perSecond }
+}
+
+// WithMaxPerHost sets the maximum number of ports scanned at the same time
+// on each host. See Config.MaxPerHost.
+func WithMaxPerHost(n int) Option {
+	return func(s *Scanner) { s.cfg.MaxPerHost = n }
+}
+
+// WithNetworkLimits sets the limits of the scans in some networks. See
+// Config.NetworkLimits.
+func WithNetworkLimits(limits ...NetworkLimit) Option {
+	return func(s *Scanner) { s.cfg.NetworkLimits = limits }
+}
+
+// WithIPVersion restricts the scan to the IPv4 addresses of the hosts when
+// v is 4, or to their IPv6 addresses when v is 6. See Config.IPVersion.
+func WithIPVersion(v int) Option {
+	return func(s *Scanner) { s.cfg.IPVersion = v }
+}
+
+// WithFirstAddress scans only the first address of each host. See
+// Config.FirstAddress.
+func WithFirstAddress() Option {
+	return func(s *Scanner) { s.cfg.FirstAddress = true }
+}
+
+// Config returns the configuration of the Scanner.
+func (s *Scanner) Config() Config {
+	return s.cfg
+}
+
+// ScanHost scans the ports of a single host, a hostname or an IP address.
+// It returns the results collected so far along with ctx.Err() when ctx is
+// done before the end of the scan.
+func (s *Scanner) ScanHost(ctx context.Context, host string, ports []int) (Results, error) {
+	scans, err := s.scan(ctx, ports, func(dispatch func(string) bool, _ func(string)) {
+		dispatch(host)
+	}, nil)
+
+	if len(scans) == 0 || !scans[0].resolved {
+		return Results{Host: host}, err
+	}
+
+	return s.results(scans[0]), err
+}
+
+// ScanHosts scans the ports of the hosts, which can also be CIDR blocks and
+// ranges, as in a HostsList. Results are returned in the same order as the
+// hosts, and the PortStates of each host follow the order of its addresses,
+// then of ports, then of Config.UDPPorts.
+//
+// When ctx is done, it returns the results collected so far along with
+// ctx.Err(). Hosts that were not resolved and ports that were not scanned
+// before the interruption are left out of the results.
+func (s *Scanner) ScanHosts(ctx context.Context, hosts []string, ports []int) ([]Results, error) {
+	return s.scanHosts(ctx, hosts, ports, nil)
+}
+
+// scanHosts works like ScanHosts, and also calls fn, when not nil, with the
+// Results of each host as soon as it is scanned, one host at a time. Once
+// the workers stopped, fn gets the partial results of the hosts interrupted
+// by ctx.
+func (s *Scanner) scanHosts(ctx context.Context, hosts []string, ports []int, fn func(Results)) ([]Results, error) {
+	var emit func(Event)
+
+	if fn != nil {
+		var mu sync.Mutex
+
+		emit = func(e Event) {
+			if e.Type != EventHostDone {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			fn(*e.Results)
+		}
+	}
+
+	scans, err := s.scan(ctx, ports, expandTargets(ctx, hosts), emit)
+
+	if scans == nil && err != nil {
+		return nil, err
+	}
+
+	res := make([]Results, 0, len(scans))
+	for _, h := range scans {
+		if h.resolved {
+			res = append(res, s.results(h))
+		}
+	}
+
+	return res, err
+}
+
+// ScanStream scans the ports of the hosts received from targets, until
+// targets is closed or ctx is done, and sends the Results of each host on
+// the returned channel as soon as it is scanned. Targets can also be CIDR
+// blocks and ranges. The channel is closed at the end of the scan, after
+// the partial results of the hosts interrupted by ctx, so callers must read
+// it until then.
+func (s *Scanner) ScanStream(ctx context.Context, targets <-chan string, ports []int) <-chan Results {
+	out := make(chan Results)
+
+	go func() {
+		defer close(out)
+
+		s.scan(ctx, ports, func(dispatch func(string) bool, invalid func(string)) {
+			for {
+				var entry string
+				var ok bool
+
+				select {
+				case entry, ok = <-targets:
+				case <-ctx.Done():
+					return
+				}
+
+				if !ok {
+					return
+				}
+
+				if err := ExpandHost(entry, dispatch); err != nil {
+					invalid(entry)
+				}
+			}
+		}, func(ev Event) {
+			if ev.Type == EventHostDone {
+				out <- *ev.Results
+			}
+		})
+	}()
+
+	return out
+}
+
+// expandTargets returns the feed of scan dispatching the hosts of targets,
+// which can also be CIDR blocks and ranges, until ctx is done.
+func expandTargets(ctx context.Context, targets []string) func(dispatch func(string) bool, invalid func(string)) {
+	return func(dispatch func(string) bool, invalid func(string)) {
+		for _, entry := range targets {
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err := ExpandHost(entry, dispatch); err != nil {
+				invalid(entry)
+			}
+		}
+	}
+}
+
+// hostScan tracks the progress of a single host while it is scanned, so
+// partial results can be reported when the scan is interrupted.
+type hostScan struct {
+	Results
+	index    int
+	resolved bool
+	scanned  []bool
+	// throttles limit the scans of the ports of the host.
+	throttles []*throttle
+	// rtt tunes the timeout of the host when Config.Adaptive is true.
+	rtt *rttEstimator
+	// pending counts the ports left to scan, and done is set once they are
+	// all scanned and the host was reported.
+	pending atomic.Int32
+	done    bool
+}
+
+// results returns the Results for the ports of h that were scanned.
+func (s *Scanner) results(h *hostScan) Results {
+	r := h.Results
+	r.PortStates = nil
+
+	if r.NotFound {
+		return r
+	}
+
+	r.PortStates = make([]PortState, 0, len(h.PortStates))
+	for i, ps := range h.PortStates {
+		if h.scanned[i] {
+			r.PortStates = append(r.PortStates, ps)
+		}
+	}
+
+	if s.cfg.CheckTLS {
+		r.Findings = tlsFindings(r.PortStates, s.cfg.certExpiryDays())
+	}
+
+	if h.rtt != nil {
+		r.Timing = h.rtt.timing()
+	}
+
+	return r
+}
+
+// portJob represents a single port to scan on a host that was found.
+type portJob struct {
+	h     *hostScan
+	index int
+	addr  string
+	port  int
+	proto string
+}
+
+// scan runs the scan of the hosts passed by feed to its dispatch function,
+// which returns false once ctx is done. Entries passed to its invalid
+// function, because they cannot be expanded, are reported as not found.
+// When emit is not nil, it is called with the events of the scan as they
+// happen, from several goroutines, but never with an EventScanDone. The
+// hosts interrupted by ctx get an EventHostDone with their partial results
+// at the end.
+//
+// It returns the hosts in the order they were dispatched, once all the
+// workers are done.
+func (s *Scanner) scan(ctx context.Context, ports []int, feed func(dispatch func(string) bool, invalid func(string)), emit func(Event)) ([]*hostScan, error) {
+	cfg := s.cfg
+
+	var syn *synScanner
+
+	switch cfg.Technique {
+	case "", TechniqueConnect:
+	case TechniqueSYN:
+		var err error
+		if syn, err = newSYNScanner(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSYNUnavailable, err)
+		}
+		defer syn.close()
+	default:
+		return nil, fmt.Errorf("unknown scan technique %q", cfg.Technique)
+	}
+
+	if cfg.IPVersion != 0 && cfg.IPVersion != 4 && cfg.IPVersion != 6 {
+		return nil, fmt.Errorf("unknown IP version %d", cfg.IPVersion)
+	}
+
+	var (
+		scans []*hostScan
+		mu    sync.Mutex
+	)
+
+	if emit == nil {
+		emit = func(Event) {}
+	}
+
+	// finish reports the host once, when its last port is scanned.
+	finish := func(h *hostScan) {
+		mu.Lock()
+		h.done = true
+		mu.Unlock()
+
+		r := s.results(h)
+		emit(Event{Type: EventHostDone, Index: h.index, Host: h.Host, Results: &r})
+	}
+
+	hosts := make(chan *hostScan)
+	jobs := make(chan portJob)
+
+	global := newThrottle(cfg.Rate, 1, 0)
+
+	networks := make([]*throttle, len(cfg.NetworkLimits))
+	for i, nl := range cfg.NetworkLimits {
+		networks[i] = newThrottle(nl.Rate, nl.Burst, nl.MaxConcurrent)
+	}
+
+	// Resolvers look up each host and queue one job per port for the hosts
+	// that were found. If the host is not found, set the NotFound property
+	// to true.
+	var resolvers sync.WaitGroup
+	for i := 0; i < cfg.workers(); i++ {
+		resolvers.Add(1)
+
+		go func() {
+			defer resolvers.Done()
+
+			for h := range hosts {
+				addrs, err := cfg.resolver().LookupHost(ctx, h.Host)
+				if err != nil {
+					if ctx.Err() != nil {
+						continue
+					}
+
+					h.NotFound = true
+					h.resolved = true
+					finish(h)
+					continue
+				}
+
+				addrs = selectAddrs(addrs, cfg)
+
+				h.resolved = true
+				if len(addrs) == 0 {
+					h.NotFound = true
+					finish(h)
+					continue
+				}
+
+				h.Addrs = addrs
+				h.throttles = hostThrottles(global, networks, addrs, cfg)
+
+				if cfg.Adaptive {
+					h.rtt = newRTTEstimator(cfg.timeout())
+				}
+
+				// Each address gets its own run of ports.
+				scanAddrs := addrs
+				if cfg.FirstAddress {
+					scanAddrs = addrs[:1]
+				}
+
+				perAddr := len(ports) + len(cfg.UDPPorts)
+				h.PortStates = make([]PortState, len(scanAddrs)*perAddr)
+				h.scanned = make([]bool, len(h.PortStates))
+				h.pending.Store(int32(len(h.PortStates)))
+
+				emit(Event{Type: EventHostResolved, Index: h.index, Host: h.Host, Addrs: addrs})
+
+				if len(h.PortStates) == 0 {
+					finish(h)
+					continue
+				}
+
+				for j := range h.PortStates {
+					job := portJob{h: h, index: j, addr: scanAddrs[j/perAddr]}
+
+					if k := j % perAddr; k < len(ports) {
+						job.port, job.proto = ports[k], ProtocolTCP
+					} else {
+						job.port, job.proto = cfg.UDPPorts[k-len(ports)], ProtocolUDP
+					}
+
+					select {
+					case jobs <- job:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	// Scanners scan the port of each queued job with the engine for its
+	// protocol and technique. Each job writes to its own slot in
+	// PortStates, so the original port order is preserved.
+	var scanners sync.WaitGroup
+	for i := 0; i < cfg.workers(); i++ {
+		scanners.Add(1)
+
+		go func() {
+			defer scanners.Done()
+
+			for j := range jobs {
+				scanFn := func() (PortState, error) {
+					if j.proto == ProtocolUDP {
+						return scanUDPPort(ctx, j.addr, j.port, cfg)
+					}
+
+					cfg := cfg
+					if j.h.rtt != nil {
+						cfg.Timeout = j.h.rtt.timeout()
+					}
+
+					var (
+						ps  PortState
+						err error
+					)
+
+					if syn != nil {
+						ps, err = syn.scanPort(ctx, j.h.Host, j.addr, j.port, cfg)
+					} else {
+						ps, err = scanPort(ctx, j.h.Host, j.addr, j.port, cfg)
+					}
+
+					if err == nil && j.h.rtt != nil && answered(ps) {
+						j.h.rtt.sample(ps.Latency)
+					}
+
+					return ps, err
+				}
+
+				ps, err := retry(ctx, j.h.throttles, cfg.Retries, scanFn)
+				if err != nil {
+					continue
+				}
+
+				j.h.PortStates[j.index] = ps
+				j.h.scanned[j.index] = true
+
+				emit(Event{Type: EventPortResult, Index: j.h.index, Host: j.h.Host, Port: &ps})
+
+				if j.h.pending.Add(-1) == 0 {
+					finish(j.h)
+				}
+			}
+		}()
+	}
+
+	// Expand CIDR blocks and ranges one address at a time while feeding the
+	// resolvers, so large blocks are never built up front. Both functions
+	// are only called from feed, so next needs no lock.
+	next := 0
+
+	dispatch := func(host string) bool {
+		h := &hostScan{Results: Results{Host: host}, index: next}
+
+		select {
+		case hosts <- h:
+			mu.Lock()
+			scans = append(scans, h)
+			mu.Unlock()
+
+			next++
+
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	invalid := func(entry string) {
+		h := &hostScan{Results: Results{Host: entry, NotFound: true}, index: next, resolved: true}
+
+		mu.Lock()
+		scans = append(scans, h)
+		mu.Unlock()
+
+		next++
+
+		finish(h)
+	}
+
+	feed(dispatch, invalid)
+
+	close(hosts)
+	resolvers.Wait()
+	close(jobs)
+	scanners.Wait()
+
+	for _, h := range scans {
+		if h.resolved && !h.done {
+			r := s.results(h)
+			emit(Event{Type: EventHostDone, Index: h.index, Host: h.Host, Results: &r})
+		}
+	}
+
+	return scans, ctx.Err()
+}
+
+// retry runs scanFn, within the limits of the throttles, and runs it again
+// up to retries times while the port does not answer.
+func retry(ctx context.Context, throttles []*throttle, retries int, scanFn func() (PortState, error)) (PortState, error) {
+	for attempt := 0; ; attempt++ {
+		release, err := acquireAll(ctx, throttles)
+		if err != nil {
+			return PortState{}, err
+		}
+
+		ps, err := scanFn()
+		release()
+
+		if err != nil || ps.Reason != ReasonNoResponse || attempt >= retries {
+			return ps, err
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package scan_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dbaker1298/pScan/scan"
+	"github.com/Dbaker1298/pScan/scan/scantest"
+)
+
+// testNetwork returns a network with web.test, with ports 22 and 80 open,
+// and 10.0.0.0/30, with port 22 open on each address.
+func testNetwork() *scantest.Network {
+	n := scantest.NewNetwork()
+
+	web := n.AddHost("web.test")
+	web.TCP(22, scan.StateOpen)
+	web.TCP(80, scan.StateOpen)
+
+	for _, addr := range []string{"10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		n.AddHost(addr).TCP(22, scan.StateOpen)
+	}
+
+	return n
+}
+
+func TestNewScannerOptions(t *testing.T) {
+	n := scantest.NewNetwork()
+	probe := scan.LookupProbe("ssh")
+
+	s := scan.NewScanner(
+		scan.WithConfig(scan.Config{Banners: true, Concurrency: 5}),
+		scan.WithTimeout(2*time.Second),
+		scan.WithConcurrency(10),
+		scan.WithRetries(3),
+		scan.WithResolver(n),
+		scan.WithDialer(n),
+		scan.WithProbes(probe),
+		scan.WithRateLimit(100),
+	)
+
+	cfg := s.Config()
+
+	if !cfg.Banners || cfg.Timeout != 2*time.Second || cfg.Concurrency != 10 || cfg.Retries != 3 || cfg.Rate != 100 {
+		t.Errorf("Expected the options applied over the configuration, got %+v instead\n", cfg)
+	}
+
+	if cfg.Resolver != n || cfg.Dialer != n || len(cfg.Probes) != 1 || cfg.Probes[0].Name() != probe.Name() {
+		t.Errorf("Expected the resolver, dialer and probe to be set, got %+v instead\n", cfg)
+	}
+}
+
+func TestScannerScanHost(t *testing.T) {
+	n := testNetwork()
+	s := scan.NewScanner(scan.WithDialer(n), scan.WithResolver(n))
+
+	res, err := s.ScanHost(context.Background(), "web.test", []int{22, 80, 443})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+
+	expected := []scan.State{scan.StateOpen, scan.StateOpen, scan.StateClosed}
+
+	if res.Host != "web.test" || len(res.PortStates) != len(expected) {
+		t.Fatalf("Expected 3 ports for web.test, got %+v instead\n", res)
+	}
+
+	for i, state := range expected {
+		if res.PortStates[i].State != state {
+			t.Errorf("Expected port %d %s, got %s instead\n", res.PortStates[i].Port, state, res.PortStates[i].State)
+		}
+	}
+
+	res, err = s.ScanHost(context.Background(), "gone.test", []int{22})
+	if err != nil || !res.NotFound {
+		t.Errorf("Expected gone.test not found, got %+v (%v) instead\n", res, err)
+	}
+}
+
+func TestScannerScanHosts(t *testing.T) {
+	n := testNetwork()
+	s := scan.NewScanner(scan.WithDialer(n), scan.WithResolver(n), scan.WithConcurrency(2))
+
+	res, err := s.ScanHosts(context.Background(), []string{"web.test", "10.0.0.0/30", "10.0.0.9-1"}, []int{22})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+
+	expected := []string{"web.test", "10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.9-1"}
+
+	if len(res) != len(expected) {
+		t.Fatalf("Expected %d results, got %d instead\n", len(expected), len(res))
+	}
+
+	for i, host := range expected {
+		if res[i].Host != host {
+			t.Errorf("Expected host %q at %d, got %q instead\n", host, i, res[i].Host)
+		}
+	}
+
+	if !res[5].NotFound {
+		t.Errorf("Expected the invalid range to be not found\n")
+	}
+}
+
+func TestScannerScanStream(t *testing.T) {
+	n := testNetwork()
+	s := scan.NewScanner(scan.WithDialer(n), scan.WithResolver(n))
+
+	targets := make(chan string)
+
+	go func() {
+		defer close(targets)
+
+		for _, target := range []string{"web.test", "10.0.0.0/31", "gone.test"} {
+			targets <- target
+		}
+	}()
+
+	var hosts []string
+
+	for r := range s.ScanStream(context.Background(), targets, []int{22}) {
+		hosts = append(hosts, r.Host)
+
+		if r.Host != "gone.test" && (len(r.PortStates) != 1 || r.PortStates[0].State != scan.StateOpen) {
+			t.Errorf("Expected port 22 open on %s, got %+v instead\n", r.Host, r.PortStates)
+		}
+	}
+
+	sort.Strings(hosts)
+
+	expected := []string{"10.0.0.0", "10.0.0.1", "gone.test", "web.test"}
+
+	if len(hosts) != len(expected) {
+		t.Fatalf("Expected hosts %v, got %v instead\n", expected, hosts)
+	}
+
+	for i := range expected {
+		if hosts[i] != expected[i] {
+			t.Errorf("Expected hosts %v, got %v instead\n", expected, hosts)
+			break
+		}
+	}
+}
+
+func TestScannerRetries(t *testing.T) {
+	testCases := []struct {
+		name     string
+		retries  int
+		expected scan.State
+	}{
+		{"NoRetry", 0, scan.StateFiltered},
+		{"Retry", 2, scan.StateOpen},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			n := scantest.NewNetwork()
+			n.AddHost("web.test").TCP(80, scan.StateOpen).Flaky(2)
+
+			s := scan.NewScanner(scan.WithDialer(n), scan.WithResolver(n), scan.WithRetries(tc.retries))
+
+			res, err := s.ScanHost(context.Background(), "web.test", []int{80})
+			if err != nil {
+				t.Fatalf("Expected no error, got %q\n", err)
+			}
+
+			if res.PortStates[0].State != tc.expected {
+				t.Errorf("Expected %s, got %s instead\n", tc.expected, res.PortStates[0].State)
+			}
+		})
+	}
+}
+
+func TestScannerRateLimit(t *testing.T) {
+	n := testNetwork()
+	s := scan.NewScanner(scan.WithDialer(n), scan.WithResolver(n), scan.WithRateLimit(50))
+
+	start := time.Now()
+
+	if _, err := s.ScanHost(context.Background(), "web.test", []int{1, 2, 3, 4, 5, 6}); err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+
+	// The first port is scanned right away, then one every 20ms.
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected the scan to take at least 100ms, took %s\n", elapsed)
+	}
+}
+
+func TestScannerLimits(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    []scan.Option
+		hosts   []string
+		minTime time.Duration
+	}{
+		// 2 hosts with 3 ports each, taking 30ms to answer.
+		{"NoLimit", nil, []string{"10.0.0.1", "10.0.0.2"}, 30 * time.Millisecond},
+		{"MaxPerHost", []scan.Option{scan.WithMaxPerHost(1)}, []string{"10.0.0.1", "10.0.0.2"}, 90 * time.Millisecond},
+		{"HostRate", []scan.Option{scan.WithHostRateLimit(20)}, []string{"10.0.0.1", "10.0.0.2"}, 100 * time.Millisecond},
+		{"Network", []scan.Option{scan.WithNetworkLimits(scan.NetworkLimit{
+			Prefix:        netip.MustParsePrefix("10.0.0.0/30"),
+			MaxConcurrent: 1,
+		})}, []string{"10.0.0.1", "10.0.0.2"}, 180 * time.Millisecond},
+		{"NetworkRate", []scan.Option{scan.WithNetworkLimits(scan.NetworkLimit{
+			Prefix: netip.MustParsePrefix("10.0.0.0/30"),
+			Rate:   50,
+			Burst:  2,
+		})}, []string{"10.0.0.1", "10.0.0.2"}, 80 * time.Millisecond},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			n := scantest.NewNetwork()
+			n.AddHost("10.0.0.1").WithLatency(30 * time.Millisecond)
+			n.AddHost("10.0.0.2").WithLatency(30 * time.Millisecond)
+
+			opts := append([]scan.Option{scan.WithDialer(n), scan.WithResolver(n)}, tc.opts...)
+			s := scan.NewScanner(opts...)
+
+			start := time.Now()
+
+			res, err := s.ScanHosts(context.Background(), tc.hosts, []int{1, 2, 3})
+			if err != nil {
+				t.Fatalf("Expected no error, got %q\n", err)
+			}
+
+			elapsed := time.Since(start)
+
+			if len(res) != 2 || len(res[0].PortStates) != 3 || len(res[1].PortStates) != 3 {
+				t.Fatalf("Expected 3 ports on 2 hosts, got %+v instead\n", res)
+			}
+
+			if elapsed < tc.minTime {
+				t.Errorf("Expected the scan to take at least %s, took %s\n", tc.minTime, elapsed)
+			}
+
+			if elapsed > tc.minTime+200*time.Millisecond {
+				t.Errorf("Expected the scan to take about %s, took %s\n", tc.minTime, elapsed)
+			}
+		})
+	}
+}
+
+// timedDialer records when each dial starts, before passing it on to the
+// network.
+type timedDialer struct {
+	n     *scantest.Network
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (d *timedDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.mu.Lock()
+	d.times = append(d.times, time.Now())
+	d.mu.Unlock()
+
+	return d.n.DialContext(ctx, network, address)
+}
+
+func TestScannerRateWithMaxPerHost(t *testing.T) {
+	n := scantest.NewNetwork()
+	n.AddHost("10.0.0.1").WithLatency(150 * time.Millisecond)
+	n.AddHost("10.0.0.2").WithLatency(150 * time.Millisecond)
+
+	d := &timedDialer{n: n}
+	s := scan.NewScanner(scan.WithDialer(d), scan.WithResolver(n),
+		scan.WithRateLimit(20), scan.WithMaxPerHost(1))
+
+	if _, err := s.ScanHosts(context.Background(), []string{"10.0.0.1", "10.0.0.2"}, []int{1, 2, 3}); err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+
+	if len(d.times) != 6 {
+		t.Fatalf("Expected 6 dials, got %d instead\n", len(d.times))
+	}
+
+	sort.Slice(d.times, func(i, j int) bool { return d.times[i].Before(d.times[j]) })
+
+	// Ports waiting for their host are not counted by the rate, so dials
+	// stay 50ms apart.
+	for i := 1; i < len(d.times); i++ {
+		if gap := d.times[i].Sub(d.times[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("Expected dials at least 50ms apart, dials %d and %d are %s apart\n", i-1, i, gap)
+		}
+	}
+}
+
+func TestScannerAdaptiveTimeout(t *testing.T) {
+	n := scantest.NewNetwork()
+
+	h := n.AddHost("web.test").WithLatency(20 * time.Millisecond)
+	h.TCP(22, scan.StateOpen)
+	h.TCP(9999, scan.StateFiltered).WithLatency(5 * time.Second)
+
+	// Scan one port at a time, so the filtered port comes after the others
+	// answered.
+	s := scan.NewScanner(scan.WithDialer(n), scan.WithResolver(n), scan.WithConcurrency(1),
+		scan.WithTimeout(2*time.Second), scan.WithAdaptiveTimeout())
+
+	res, err := s.ScanHost(context.Background(), "web.test", []int{22, 80, 443, 9999})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+
+	timing := res.Timing
+	if timing == nil || timing.Samples != 3 {
+		t.Fatalf("Expected timing from 3 samples, got %+v instead\n", timing)
+	}
+
+	if timing.Timeout != scan.MinAdaptiveTimeout || timing.SRTT < 20*time.Millisecond {
+		t.Errorf("Expected a timeout of %s from an rtt of 20ms, got %+v instead\n", scan.MinAdaptiveTimeout, timing)
+	}
+
+	if ps := res.PortStates[3]; ps.State != scan.StateFiltered || ps.Latency > time.Second {
+		t.Errorf("Expected port 9999 filtered after the adaptive timeout, got %s after %s\n", ps.State, ps.Latency)
+	}
+
+	// Without adaptive timing, there is no timing in the results.
+	res, err = scan.NewScanner(scan.WithDialer(n), scan.WithResolver(n)).ScanHost(context.Background(), "web.test", []int{22})
+	if err != nil || res.Timing != nil {
+		t.Errorf("Expected no timing, got %+v (%v) instead\n", res.Timing, err)
+	}
+}
+
+func TestScannerAddresses(t *testing.T) {
+	n := scantest.NewNetwork()
+	n.AddHost("dual.test", "192.0.2.10", "2001:db8::10").TCP(22, scan.StateOpen)
+	n.AddHost("v4.test", "192.0.2.20").TCP(22, scan.StateOpen)
+
+	testCases := []struct {
+		name     string
+		host     string
+		opts     []scan.Option
+		expected []string
+	}{
+		{"All", "dual.test", nil, []string{"192.0.2.10", "2001:db8::10"}},
+		{"IPv4", "dual.test", []scan.Option{scan.WithIPVersion(4)}, []string{"192.0.2.10"}},
+		{"IPv6", "dual.test", []scan.Option{scan.WithIPVersion(6)}, []string{"2001:db8::10"}},
+		{"FirstAddress", "dual.test", []scan.Option{scan.WithFirstAddress()}, []string{"192.0.2.10"}},
+		{"FirstIPv6Address", "dual.test", []scan.Option{scan.WithIPVersion(6), scan.WithFirstAddress()}, []string{"2001:db8::10"}},
+		{"NoIPv6Address", "v4.test", []scan.Option{scan.WithIPVersion(6)}, nil},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append([]scan.Option{scan.WithDialer(n), scan.WithResolver(n)}, tc.opts...)
+
+			res, err := scan.NewScanner(opts...).ScanHost(context.Background(), tc.host, []int{22, 80})
+			if err != nil {
+				t.Fatalf("Expected no error, got %q\n", err)
+			}
+
+			if tc.expected == nil {
+				if !res.NotFound || len(res.PortStates) != 0 {
+					t.Errorf("Expected the host not found, got %+v instead\n", res)
+				}
+
+				return
+			}
+
+			if len(res.PortStates) != 2*len(tc.expected) {
+				t.Fatalf("Expected 2 ports on each of %v, got %+v instead\n", tc.expected, res.PortStates)
+			}
+
+			// Ports follow the order of the addresses, then of the ports.
+			for i, ps := range res.PortStates {
+				addr, port := tc.expected[i/2], []int{22, 80}[i%2]
+
+				if ps.Address != addr || ps.Port != port {
+					t.Errorf("Expected port %d on %s, got %d on %s instead\n", port, addr, ps.Port, ps.Address)
+				}
+			}
+
+			if res.PortStates[0].State != scan.StateOpen {
+				t.Errorf("Expected port 22 open, got %s instead\n", res.PortStates[0].State)
+			}
+		})
+	}
+
+	if _, err := scan.NewScanner(scan.WithIPVersion(5)).ScanHost(context.Background(), "dual.test", []int{22}); err == nil {
+		t.Error("Expected an error for IP version 5, got none")
+	}
+}
@@ -0,0 +1,374 @@
+// Package scantest provides a simulated network to test code built on the
+// scan package deterministically, without touching the host network.
+//
+// A Network holds the declared hosts and the state of their ports, and
+// implements both scan.Dialer and scan.Resolver:
+//
+//	n := scantest.NewNetwork()
+//
+//	web := n.AddHost("web.test")
+//	web.TCP(22, scan.StateOpen).WithBanner("SSH-2.0-OpenSSH_9.3\r\n")
+//	web.TCP(443, scan.StateFiltered).WithLatency(50 * time.Millisecond)
+//	web.UDP(53, scan.StateOpen).WithBanner("answer")
+//
+//	n.FailLookup("gone.test", nil)
+//
+//	res := scan.RunWithConfig(hl, []int{22, 80, 443}, scan.Config{Dialer: n, Resolver: n})
+//
+// Ports that are not declared are closed, as on real hosts. Filtered ports
+// time out right after their latency, instead of waiting for the timeout of
+// the scan, so tests stay fast.
+package scantest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+// Network is a simulated network. Declare its hosts before scanning it;
+// it is safe to use from concurrent scans afterwards.
+type Network struct {
+	mu      sync.Mutex
+	hosts   map[string]*Host
+	lookups map[string]error
+	dials   []string
+	next    netip.Addr
+}
+
+// NewNetwork returns an empty network.
+func NewNetwork() *Network {
+	return &Network{
+		hosts:   map[string]*Host{},
+		lookups: map[string]error{},
+		next:    netip.MustParseAddr("192.0.2.1"),
+	}
+}
+
+// AddHost declares a host named name, which resolves to addrs. Without
+// addresses, a host named after an IP address gets that address, and other
+// hosts get the next free one in 192.0.2.0/24. All the ports of the host
+// start closed.
+func (n *Network) AddHost(name string, addrs ...string) *Host {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(addrs) == 0 {
+		if _, err := netip.ParseAddr(name); err == nil {
+			addrs = []string{name}
+		} else {
+			addrs = []string{n.next.String()}
+			n.next = n.next.Next()
+		}
+	}
+
+	h := &Host{network: n, name: name, addrs: addrs, ports: map[portKey]*Port{}}
+
+	n.hosts[name] = h
+	for _, a := range addrs {
+		n.hosts[a] = h
+	}
+
+	return h
+}
+
+// FailLookup makes the lookups of name fail with err, or with a not found
+// *net.DNSError when err is nil.
+func (n *Network) FailLookup(name string, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err == nil {
+		err = &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	n.lookups[name] = err
+}
+
+// Dials returns the dials made so far, in order, as the network and the
+// address, such as "tcp web.test:22".
+func (n *Network) Dials() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return append([]string(nil), n.dials...)
+}
+
+// LookupHost implements scan.Resolver. IP addresses resolve to themselves,
+// declared hosts to their addresses, and other names are not found.
+func (n *Network) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err, ok := n.lookups[host]; ok {
+		return nil, err
+	}
+
+	if _, err := netip.ParseAddr(host); err == nil {
+		return []string{host}, nil
+	}
+
+	if h, ok := n.hosts[host]; ok && h.name == host {
+		return append([]string(nil), h.addrs...), nil
+	}
+
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// DialContext implements scan.Dialer for the tcp and udp networks. Dials
+// to addresses without a declared host fail as unreachable.
+func (n *Network) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	proto := ""
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		proto = scan.ProtocolTCP
+	case "udp", "udp4", "udp6":
+		proto = scan.ProtocolUDP
+	default:
+		return nil, &net.OpError{Op: "dial", Net: network, Err: net.UnknownNetworkError(network)}
+	}
+
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: fmt.Errorf("invalid port %q", portStr)}
+	}
+
+	n.mu.Lock()
+	n.dials = append(n.dials, network+" "+address)
+
+	h := n.hosts[host]
+
+	var (
+		p      *Port
+		silent bool
+	)
+
+	if h != nil {
+		p = h.ports[portKey{proto, port}]
+		if p != nil && p.flaky > 0 {
+			p.flaky--
+			silent = true
+		}
+	}
+	n.mu.Unlock()
+
+	if h == nil {
+		return nil, dialError(network, syscall.EHOSTUNREACH)
+	}
+
+	if proto == scan.ProtocolUDP {
+		return &udpConn{port: p, latency: h.latencyOf(p), silent: silent, remote: address}, nil
+	}
+
+	if err := wait(ctx, h.latencyOf(p)); err != nil {
+		return nil, dialError(network, err)
+	}
+
+	state := scan.StateClosed
+	if p != nil {
+		state = p.state
+	}
+
+	switch {
+	case silent:
+		return nil, dialError(network, os.ErrDeadlineExceeded)
+	case state == scan.StateOpen:
+		return p.accept(), nil
+	case state == scan.StateClosed:
+		return nil, dialError(network, syscall.ECONNREFUSED)
+	case state == scan.StateUnreachable:
+		return nil, dialError(network, syscall.EHOSTUNREACH)
+	}
+
+	return nil, dialError(network, os.ErrDeadlineExceeded)
+}
+
+// dialError wraps err as the net package reports failed dials.
+func dialError(network string, err error) error {
+	return &net.OpError{Op: "dial", Net: network, Err: err}
+}
+
+// wait sleeps for d, or until ctx is done. Like net.Dialer, it reports an
+// expired ctx as a timeout.
+func wait(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return os.ErrDeadlineExceeded
+		}
+
+		return ctx.Err()
+	}
+}
+
+// portKey identifies a port of a host.
+type portKey struct {
+	proto string
+	port  int
+}
+
+// Host is a host of the simulated network.
+type Host struct {
+	network *Network
+	name    string
+	addrs   []string
+	latency time.Duration
+	ports   map[portKey]*Port
+}
+
+// Addrs returns the addresses the host resolves to.
+func (h *Host) Addrs() []string {
+	return append([]string(nil), h.addrs...)
+}
+
+// WithLatency sets how long the host takes to answer, on the ports without
+// their own latency.
+func (h *Host) WithLatency(d time.Duration) *Host {
+	h.network.mu.Lock()
+	defer h.network.mu.Unlock()
+
+	h.latency = d
+
+	return h
+}
+
+// TCP declares a TCP port of the host in the state: scan.StateOpen,
+// scan.StateClosed, scan.StateFiltered or scan.StateUnreachable.
+func (h *Host) TCP(port int, state scan.State) *Port {
+	return h.addPort(scan.ProtocolTCP, port, state)
+}
+
+// UDP declares a UDP port of the host in the state. Open ports answer each
+// datagram with their banner, closed ones report the port as unreachable,
+// and filtered ones never answer.
+func (h *Host) UDP(port int, state scan.State) *Port {
+	return h.addPort(scan.ProtocolUDP, port, state)
+}
+
+func (h *Host) addPort(proto string, port int, state scan.State) *Port {
+	h.network.mu.Lock()
+	defer h.network.mu.Unlock()
+
+	p := &Port{host: h, state: state, latency: -1}
+	h.ports[portKey{proto, port}] = p
+
+	return p
+}
+
+// latencyOf returns the latency of the port, or of the host for closed
+// ports and ports without their own latency.
+func (h *Host) latencyOf(p *Port) time.Duration {
+	h.network.mu.Lock()
+	defer h.network.mu.Unlock()
+
+	if p != nil && p.latency >= 0 {
+		return p.latency
+	}
+
+	return h.latency
+}
+
+// Port is a port of a simulated host.
+type Port struct {
+	host    *Host
+	state   scan.State
+	banner  []byte
+	latency time.Duration
+	flaky   int
+	serve   func(conn net.Conn)
+}
+
+// WithBanner sets what the open port sends right after the connection, or
+// answers to datagrams for UDP ports.
+func (p *Port) WithBanner(banner string) *Port {
+	p.host.network.mu.Lock()
+	defer p.host.network.mu.Unlock()
+
+	p.banner = []byte(banner)
+
+	return p
+}
+
+// WithLatency sets how long the port takes to answer.
+func (p *Port) WithLatency(d time.Duration) *Port {
+	p.host.network.mu.Lock()
+	defer p.host.network.mu.Unlock()
+
+	p.latency = d
+
+	return p
+}
+
+// Flaky makes the next count connections to the port go unanswered, as if
+// the packets were lost. For UDP ports, that is every datagram sent on the
+// next count sockets.
+func (p *Port) Flaky(count int) *Port {
+	p.host.network.mu.Lock()
+	defer p.host.network.mu.Unlock()
+
+	p.flaky = count
+
+	return p
+}
+
+// Serve makes the open TCP port run fn on the server end of each
+// connection, after sending the banner. The connection is closed when fn
+// returns. Without it, the port closes the connection after the banner.
+func (p *Port) Serve(fn func(conn net.Conn)) *Port {
+	p.host.network.mu.Lock()
+	defer p.host.network.mu.Unlock()
+
+	p.serve = fn
+
+	return p
+}
+
+// accept returns the client end of a new in-memory connection to the open
+// port, served in its own goroutine.
+func (p *Port) accept() net.Conn {
+	p.host.network.mu.Lock()
+	banner, serve := p.banner, p.serve
+	p.host.network.mu.Unlock()
+
+	client, server := net.Pipe()
+
+	go func() {
+		defer server.Close()
+
+		if len(banner) > 0 {
+			if _, err := server.Write(banner); err != nil {
+				return
+			}
+		}
+
+		if serve != nil {
+			serve(server)
+		}
+	}()
+
+	return client
+}
@@ -0,0 +1,193 @@
+package scantest_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Dbaker1298/pScan/scan"
+	"github.com/Dbaker1298/pScan/scan/scantest"
+)
+
+func TestNetworkTCP(t *testing.T) {
+	n := scantest.NewNetwork()
+
+	web := n.AddHost("web.test")
+	web.TCP(22, scan.StateOpen).WithBanner("SSH-2.0-Fake\r\n")
+	web.TCP(443, scan.StateFiltered)
+	web.TCP(8080, scan.StateUnreachable)
+	web.TCP(8443, scan.StateOpen).WithLatency(20 * time.Millisecond)
+
+	hl := &scan.HostsList{}
+
+	hl.Add("web.test")
+
+	cfg := scan.Config{Dialer: n, Resolver: n, Banners: true}
+
+	res := scan.RunWithConfig(hl, []int{22, 80, 443, 8080, 8443}, cfg)
+
+	if len(res) != 1 || len(res[0].PortStates) != 5 {
+		t.Fatalf("Expected 1 host with 5 ports, got %v instead\n", res)
+	}
+
+	if len(res[0].Addrs) != 1 || res[0].Addrs[0] != "192.0.2.1" {
+		t.Errorf("Expected address 192.0.2.1, got %v instead\n", res[0].Addrs)
+	}
+
+	testCases := []struct {
+		name   string
+		state  scan.State
+		reason string
+		banner string
+	}{
+		{"Open", scan.StateOpen, scan.ReasonSynAck, "SSH-2.0-Fake"},
+		{"Closed", scan.StateClosed, scan.ReasonConnRefused, ""},
+		{"Filtered", scan.StateFiltered, scan.ReasonNoResponse, ""},
+		{"Unreachable", scan.StateUnreachable, scan.ReasonHostUnreach, ""},
+		{"Latency", scan.StateOpen, scan.ReasonSynAck, ""},
+	}
+
+	for i, tc := range testCases {
+		tc, ps := tc, res[0].PortStates[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			if ps.State != tc.state || ps.Reason != tc.reason {
+				t.Errorf("Expected %s (%s), got %s (%s) instead\n", tc.state, tc.reason, ps.State, ps.Reason)
+			}
+
+			if ps.Banner != tc.banner {
+				t.Errorf("Expected banner %q, got %q instead\n", tc.banner, ps.Banner)
+			}
+		})
+	}
+
+	if l := res[0].PortStates[4].Latency; l < 20*time.Millisecond {
+		t.Errorf("Expected latency of at least 20ms, got %s instead\n", l)
+	}
+
+	dials := n.Dials()
+	sort.Strings(dials)
+
+	if len(dials) != 5 || dials[0] != "tcp 192.0.2.1:22" {
+		t.Errorf("Expected 5 dials including tcp 192.0.2.1:22, got %v instead\n", dials)
+	}
+}
+
+func TestNetworkUDP(t *testing.T) {
+	n := scantest.NewNetwork()
+
+	dns := n.AddHost("10.0.0.53")
+	dns.UDP(53, scan.StateOpen).WithBanner("answer")
+	dns.UDP(123, scan.StateFiltered)
+
+	hl := &scan.HostsList{}
+
+	hl.Add("10.0.0.53")
+
+	res := scan.RunWithConfig(hl, nil, scan.Config{Dialer: n, Resolver: n, UDPPorts: []int{53, 123, 161}})
+
+	if len(res) != 1 || len(res[0].PortStates) != 3 {
+		t.Fatalf("Expected 1 host with 3 ports, got %v instead\n", res)
+	}
+
+	expected := []scan.State{scan.StateOpen, scan.StateOpenFiltered, scan.StateClosed}
+
+	for i, state := range expected {
+		if ps := res[0].PortStates[i]; ps.State != state || ps.Protocol != scan.ProtocolUDP {
+			t.Errorf("Expected %d/udp %s, got %s/%s instead\n", ps.Port, state, ps.Protocol, ps.State)
+		}
+	}
+}
+
+func TestNetworkLookup(t *testing.T) {
+	n := scantest.NewNetwork()
+
+	n.AddHost("web.test", "192.0.2.80", "2001:db8::80")
+	n.FailLookup("down.test", errors.New("server misbehaving"))
+
+	testCases := []struct {
+		name     string
+		host     string
+		expected []string
+		notFound bool
+	}{
+		{"Declared", "web.test", []string{"192.0.2.80", "2001:db8::80"}, false},
+		{"Address", "198.51.100.7", []string{"198.51.100.7"}, false},
+		{"Unknown", "unknown.test", nil, true},
+		{"Failed", "down.test", nil, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			addrs, err := n.LookupHost(context.Background(), tc.host)
+
+			if tc.expected == nil {
+				var dnsErr *net.DNSError
+				if err == nil || errors.As(err, &dnsErr) != tc.notFound {
+					t.Errorf("Expected lookup error (not found: %t), got %v\n", tc.notFound, err)
+				}
+
+				return
+			}
+
+			if err != nil || len(addrs) != len(tc.expected) || addrs[0] != tc.expected[0] {
+				t.Errorf("Expected %v, got %v (%v) instead\n", tc.expected, addrs, err)
+			}
+		})
+	}
+}
+
+func TestNetworkFlaky(t *testing.T) {
+	n := scantest.NewNetwork()
+
+	n.AddHost("web.test").TCP(80, scan.StateOpen).Flaky(1)
+
+	hl := &scan.HostsList{}
+
+	hl.Add("web.test")
+
+	cfg := scan.Config{Dialer: n, Resolver: n}
+
+	for _, expected := range []scan.State{scan.StateFiltered, scan.StateOpen} {
+		res := scan.RunWithConfig(hl, []int{80}, cfg)
+
+		if got := res[0].PortStates[0].State; got != expected {
+			t.Errorf("Expected %s, got %s instead\n", expected, got)
+		}
+	}
+}
+
+func TestNetworkServe(t *testing.T) {
+	n := scantest.NewNetwork()
+
+	n.AddHost("db.test").TCP(6379, scan.StateOpen).Serve(func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+
+		for _, reply := range []string{"+PONG\r\n", "$22\r\nredis_version:7.2.4\r\n\r\n"} {
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+
+			conn.Write([]byte(reply))
+		}
+	})
+
+	hl := &scan.HostsList{}
+
+	hl.Add("db.test")
+
+	cfg := scan.Config{Dialer: n, Resolver: n, Probes: []scan.Probe{scan.LookupProbe("redis")}}
+
+	res := scan.RunWithConfig(hl, []int{6379}, cfg)
+
+	svc := res[0].PortStates[0].Service
+	if svc == nil || svc.Name != "redis" || svc.Version != "7.2.4" {
+		t.Fatalf("Expected Redis 7.2.4, got %+v instead\n", svc)
+	}
+}
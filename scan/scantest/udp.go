@@ -0,0 +1,128 @@
+package scantest
+
+import (
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+// udpConn is a connected UDP socket to a simulated port. Each datagram
+// written gets at most one answer, read after the latency of the port.
+type udpConn struct {
+	port    *Port
+	latency time.Duration
+	silent  bool
+	remote  string
+
+	mu       sync.Mutex
+	deadline time.Time
+	pending  int
+	closed   bool
+}
+
+// Read returns the answer to a datagram written before, or the error a
+// real socket would report for the port: a timeout when nothing answers,
+// and ECONNREFUSED when the port is closed.
+func (c *udpConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return 0, c.opError("read", net.ErrClosed)
+	}
+
+	deadline, pending := c.deadline, c.pending > 0
+	if pending {
+		c.pending--
+	}
+	c.mu.Unlock()
+
+	state := scan.StateClosed
+	if c.port != nil {
+		state = c.port.state
+	}
+
+	answers := pending && !c.silent && state != scan.StateFiltered && state != scan.StateOpenFiltered
+
+	// Like filtered TCP ports, unanswered datagrams time out right after the
+	// latency, without waiting for the deadline.
+	wait := c.latency
+	if !deadline.IsZero() && time.Until(deadline) < wait {
+		wait, answers = max(time.Until(deadline), 0), false
+	}
+
+	time.Sleep(wait)
+
+	if !answers {
+		return 0, c.opError("read", os.ErrDeadlineExceeded)
+	}
+
+	switch state {
+	case scan.StateClosed:
+		return 0, c.opError("read", syscall.ECONNREFUSED)
+	case scan.StateUnreachable:
+		return 0, c.opError("read", syscall.EHOSTUNREACH)
+	}
+
+	c.port.host.network.mu.Lock()
+	n := copy(b, c.port.banner)
+	c.port.host.network.mu.Unlock()
+
+	return n, nil
+}
+
+// Write sends a datagram to the port.
+func (c *udpConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, c.opError("write", net.ErrClosed)
+	}
+
+	c.pending++
+
+	return len(b), nil
+}
+
+func (c *udpConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+
+	return nil
+}
+
+func (c *udpConn) LocalAddr() net.Addr  { return fakeAddr("local") }
+func (c *udpConn) RemoteAddr() net.Addr { return fakeAddr(c.remote) }
+
+func (c *udpConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *udpConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deadline = t
+
+	return nil
+}
+
+func (c *udpConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func (c *udpConn) opError(op string, err error) error {
+	return &net.OpError{Op: op, Net: "udp", Addr: fakeAddr(c.remote), Err: err}
+}
+
+// fakeAddr is the address of a simulated UDP socket.
+type fakeAddr string
+
+func (a fakeAddr) Network() string { return "udp" }
+func (a fakeAddr) String() string  { return string(a) }
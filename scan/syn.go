@@ -0,0 +1,75 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// Techniques used to scan TCP ports, set in Config.Technique.
+const (
+	// TechniqueConnect completes the TCP handshake with each port, as any
+	// client would. It needs no privileges.
+	TechniqueConnect = "connect"
+	// TechniqueSYN only sends the first packet of the handshake, and tells
+	// the port state from the reply without ever completing the
+	// connection. It needs a raw socket, which is only available on Linux
+	// with the CAP_NET_RAW capability.
+	TechniqueSYN = "syn"
+)
+
+// ErrSYNUnavailable is returned when SYN scans are not possible on this
+// system, because of the operating system or missing privileges.
+var ErrSYNUnavailable = errors.New("SYN scan unavailable")
+
+// CheckSYN returns an error wrapping ErrSYNUnavailable when SYN scans are
+// not possible on this system, so callers can fall back to connect scans
+// before starting.
+func CheckSYN() error {
+	s, err := newSYNScanner()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSYNUnavailable, err)
+	}
+
+	s.close()
+
+	return nil
+}
+
+// CheckSYNAddr returns an error wrapping ErrSYNUnavailable when addr cannot
+// be scanned with SYN packets, so its TCP ports are scanned with connect
+// scans instead.
+func CheckSYNAddr(addr string) error {
+	if _, ok := synAddr(addr); !ok {
+		return fmt.Errorf("%w: IPv6 address %s", ErrSYNUnavailable, addr)
+	}
+
+	return nil
+}
+
+// synAddr returns addr as an IPv4 address. SYN packets are only crafted for
+// IPv4, so other addresses are scanned with connect scans.
+func synAddr(addr string) (netip.Addr, bool) {
+	a, err := netip.ParseAddr(addr)
+	if err != nil || !a.Unmap().Is4() {
+		return netip.Addr{}, false
+	}
+
+	return a.Unmap(), true
+}
+
+// finishSYNPort completes the scan of a port found open by a SYN scan,
+// connecting to it for the banner and inspections enabled in cfg.
+func finishSYNPort(ctx context.Context, host string, p *PortState, cfg Config) {
+	if cfg.Banners {
+		address := net.JoinHostPort(p.Address, fmt.Sprintf("%d", p.Port))
+		if conn, err := cfg.dial(ctx, "tcp", address, cfg.timeout()); err == nil {
+			p.Banner = grabBanner(conn, cfg.bannerSize(), cfg.bannerTimeout())
+			conn.Close()
+		}
+	}
+
+	inspectOpenPort(ctx, host, p, cfg)
+}
@@ -0,0 +1,365 @@
+//go:build linux
+
+package scan
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// TCP header flags matched in the replies to SYN packets.
+const (
+	tcpFIN = 1 << iota
+	tcpSYN
+	tcpRST
+	tcpPSH
+	tcpACK
+)
+
+// ICMP destination unreachable message type, the length of its header, and
+// the codes reported for SYN packets.
+const (
+	icmpDestUnreach         = 3
+	icmpNetUnreach          = 0
+	icmpHostUnreach         = 1
+	icmpNetProhibited       = 9
+	icmpHostProhibited      = 10
+	icmpAdminProhibited     = 13
+	icmpUnreachHeaderLength = 8
+)
+
+// synKey identifies the SYN packet a reply answers: the probed address and
+// port, and the sequence number of the packet.
+type synKey struct {
+	addr netip.Addr
+	port uint16
+	seq  uint32
+}
+
+// synReply is the state and reason told by the reply to a SYN packet.
+type synReply struct {
+	state  State
+	reason string
+}
+
+// synScanner sends SYN packets over a raw socket and matches the replies,
+// TCP or ICMP, to the waiting scans. All the SYN packets leave from the
+// same source port, which no local socket listens on, so the kernel resets
+// the connections opened by SYN/ACK replies.
+type synScanner struct {
+	tcp     *net.IPConn
+	icmp    *net.IPConn
+	srcPort uint16
+
+	mu      sync.Mutex
+	pending map[synKey]chan synReply
+	// sources caches the source address of each destination.
+	sources map[netip.Addr]netip.Addr
+	done    sync.WaitGroup
+}
+
+// newSYNScanner opens the raw sockets used to send SYN packets and read
+// their replies. It fails without the CAP_NET_RAW capability.
+func newSYNScanner() (*synScanner, error) {
+	tcp, err := net.ListenIP("ip4:tcp", &net.IPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &synScanner{
+		tcp:     tcp,
+		srcPort: uint16(32768 + rand.Intn(28232)), //nolint:gosec
+		pending: map[synKey]chan synReply{},
+		sources: map[netip.Addr]netip.Addr{},
+	}
+
+	s.done.Add(1)
+	go s.readTCP()
+
+	// Without ICMP, unreachable ports are reported as filtered, so the scan
+	// still works.
+	if icmp, err := net.ListenIP("ip4:icmp", &net.IPAddr{IP: net.IPv4zero}); err == nil {
+		s.icmp = icmp
+
+		s.done.Add(1)
+		go s.readICMP()
+	}
+
+	return s, nil
+}
+
+// close closes the raw sockets and waits for their readers to stop.
+func (s *synScanner) close() {
+	s.tcp.Close()
+
+	if s.icmp != nil {
+		s.icmp.Close()
+	}
+
+	s.done.Wait()
+}
+
+// scanPort performs a SYN scan on a single port of the host, at the address
+// addr. IPv6 addresses are scanned with scanPort. It returns ctx.Err() when
+// the scan was interrupted before the port state could be determined.
+func (s *synScanner) scanPort(ctx context.Context, host, addr string, port int, cfg Config) (PortState, error) {
+	dst, ok := synAddr(addr)
+	if !ok {
+		return scanPort(ctx, host, addr, port, cfg)
+	}
+
+	p := PortState{Port: port, Protocol: ProtocolTCP, Address: addr}
+
+	src, err := s.source(dst)
+	if err != nil {
+		p.State, p.Reason = classify(err)
+		p.Err = err
+
+		return p, nil
+	}
+
+	key := synKey{addr: dst, port: uint16(port), seq: rand.Uint32()} //nolint:gosec
+	replies := make(chan synReply, 1)
+
+	s.mu.Lock()
+	s.pending[key] = replies
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, key)
+		s.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(cfg.timeout())
+	defer timer.Stop()
+
+	start := time.Now()
+
+	packet := synPacket(src, dst, s.srcPort, key.port, key.seq)
+	if _, err := s.tcp.WriteToIP(packet, &net.IPAddr{IP: dst.AsSlice()}); err != nil {
+		p.Latency = time.Since(start)
+		p.State, p.Reason = classify(err)
+		p.Err = err
+
+		return p, nil
+	}
+
+	select {
+	case r := <-replies:
+		p.State, p.Reason = r.state, r.reason
+	case <-timer.C:
+		p.State, p.Reason = StateFiltered, ReasonNoResponse
+	case <-ctx.Done():
+		return p, ctx.Err()
+	}
+
+	p.Latency = time.Since(start)
+
+	if p.State == StateOpen {
+		finishSYNPort(ctx, host, &p, cfg)
+	}
+
+	return p, nil
+}
+
+// deliver hands the reply to the scan waiting for it, if any.
+func (s *synScanner) deliver(key synKey, r synReply) {
+	s.mu.Lock()
+	replies, ok := s.pending[key]
+	s.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	select {
+	case replies <- r:
+	default:
+	}
+}
+
+// readTCP reads the TCP segments received by the host, until the socket is
+// closed, and delivers the SYN/ACK and RST replies to the SYN packets.
+func (s *synScanner) readTCP() {
+	defer s.done.Done()
+
+	buf := make([]byte, 1500)
+
+	for {
+		n, from, err := s.tcp.ReadFromIP(buf)
+		if err != nil {
+			return
+		}
+
+		addr, ok := netip.AddrFromSlice(from.IP)
+		if !ok || n < 20 {
+			continue
+		}
+
+		seg := buf[:n]
+		if binary.BigEndian.Uint16(seg[2:4]) != s.srcPort {
+			continue
+		}
+
+		flags := seg[13]
+		if flags&tcpACK == 0 {
+			continue
+		}
+
+		key := synKey{
+			addr: addr.Unmap(),
+			port: binary.BigEndian.Uint16(seg[0:2]),
+			seq:  binary.BigEndian.Uint32(seg[8:12]) - 1,
+		}
+
+		switch {
+		case flags&tcpRST != 0:
+			s.deliver(key, synReply{StateClosed, ReasonReset})
+		case flags&tcpSYN != 0:
+			s.deliver(key, synReply{StateOpen, ReasonSynAck})
+		}
+	}
+}
+
+// readICMP reads the ICMP messages received by the host, until the socket
+// is closed, and delivers the destination unreachable errors about the SYN
+// packets.
+func (s *synScanner) readICMP() {
+	defer s.done.Done()
+
+	buf := make([]byte, 1500)
+
+	for {
+		n, _, err := s.icmp.ReadFromIP(buf)
+		if err != nil {
+			return
+		}
+
+		msg := buf[:n]
+		if n < icmpUnreachHeaderLength+20 || msg[0] != icmpDestUnreach {
+			continue
+		}
+
+		// The message quotes the IP header of the SYN packet, followed by
+		// at least the first 8 bytes of its TCP header.
+		orig := msg[icmpUnreachHeaderLength:]
+		ihl := int(orig[0]&0x0f) * 4
+
+		if len(orig) < ihl+8 || orig[9] != 6 {
+			continue
+		}
+
+		seg := orig[ihl:]
+		if binary.BigEndian.Uint16(seg[0:2]) != s.srcPort {
+			continue
+		}
+
+		key := synKey{
+			addr: netip.AddrFrom4([4]byte(orig[16:20])),
+			port: binary.BigEndian.Uint16(seg[2:4]),
+			seq:  binary.BigEndian.Uint32(seg[4:8]),
+		}
+
+		switch msg[1] {
+		case icmpNetUnreach:
+			s.deliver(key, synReply{StateUnreachable, ReasonNetUnreach})
+		case icmpHostUnreach:
+			s.deliver(key, synReply{StateUnreachable, ReasonHostUnreach})
+		case icmpNetProhibited, icmpHostProhibited, icmpAdminProhibited:
+			s.deliver(key, synReply{StateFiltered, ReasonAdminProhibited})
+		default:
+			s.deliver(key, synReply{StateFiltered, ReasonError})
+		}
+	}
+}
+
+// source returns the local address the kernel uses to reach dst, looked up
+// once per destination.
+func (s *synScanner) source(dst netip.Addr) (netip.Addr, error) {
+	s.mu.Lock()
+	src, ok := s.sources[dst]
+	s.mu.Unlock()
+
+	if ok {
+		return src, nil
+	}
+
+	src, err := sourceAddr(dst)
+	if err != nil {
+		return src, err
+	}
+
+	s.mu.Lock()
+	s.sources[dst] = src
+	s.mu.Unlock()
+
+	return src, nil
+}
+
+// sourceAddr returns the local address the kernel uses to reach dst.
+// Connecting a UDP socket picks the route without sending anything.
+func sourceAddr(dst netip.Addr) (netip.Addr, error) {
+	conn, err := net.DialUDP("udp4", nil, net.UDPAddrFromAddrPort(netip.AddrPortFrom(dst, 9)))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).AddrPort().Addr().Unmap(), nil
+}
+
+// synPacket builds the TCP header of a SYN packet from src to dst, with an
+// MSS option as sent by most systems. The kernel adds the IP header.
+func synPacket(src, dst netip.Addr, srcPort, dstPort uint16, seq uint32) []byte {
+	b := make([]byte, 24)
+
+	binary.BigEndian.PutUint16(b[0:2], srcPort)
+	binary.BigEndian.PutUint16(b[2:4], dstPort)
+	binary.BigEndian.PutUint32(b[4:8], seq)
+	b[12] = 6 << 4 // Data offset, in 32-bit words.
+	b[13] = tcpSYN
+	binary.BigEndian.PutUint16(b[14:16], 64240) // Window size.
+
+	// MSS option: kind 2, length 4, 1460 bytes.
+	b[20], b[21] = 2, 4
+	binary.BigEndian.PutUint16(b[22:24], 1460)
+
+	binary.BigEndian.PutUint16(b[16:18], tcpChecksum(src, dst, b))
+
+	return b
+}
+
+// tcpChecksum computes the checksum of the TCP segment, including the IPv4
+// pseudo-header.
+func tcpChecksum(src, dst netip.Addr, seg []byte) uint16 {
+	var sum uint32
+
+	add := func(b []byte) {
+		for i := 0; i+1 < len(b); i += 2 {
+			sum += uint32(binary.BigEndian.Uint16(b[i : i+2]))
+		}
+
+		if len(b)%2 == 1 {
+			sum += uint32(b[len(b)-1]) << 8
+		}
+	}
+
+	s4, d4 := src.As4(), dst.As4()
+
+	add(s4[:])
+	add(d4[:])
+	sum += 6 + uint32(len(seg))
+	add(seg)
+
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+
+	return ^uint16(sum)
+}
@@ -0,0 +1,67 @@
+//go:build linux
+
+package scan_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+func TestRunSYN(t *testing.T) {
+	if err := scan.CheckSYN(); err != nil {
+		t.Skipf("Skipping SYN scan test: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	open := ln.Addr().(*net.TCPAddr).Port
+
+	closedLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed := closedLn.Addr().(*net.TCPAddr).Port
+	closedLn.Close()
+
+	hl := &scan.HostsList{}
+
+	hl.Add("127.0.0.1")
+
+	res := scan.RunWithConfig(hl, []int{open, closed}, scan.Config{Technique: scan.TechniqueSYN})
+
+	if len(res) != 1 || len(res[0].PortStates) != 2 {
+		t.Fatalf("Expected 1 host with 2 ports, got %v instead\n", res)
+	}
+
+	testCases := []struct {
+		name   string
+		ps     scan.PortState
+		port   int
+		state  scan.State
+		reason string
+	}{
+		{"Open", res[0].PortStates[0], open, scan.StateOpen, scan.ReasonSynAck},
+		{"Closed", res[0].PortStates[1], closed, scan.StateClosed, scan.ReasonReset},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.ps.Port != tc.port {
+				t.Errorf("Expected port %d, got %d instead\n", tc.port, tc.ps.Port)
+			}
+
+			if tc.ps.State != tc.state || tc.ps.Reason != tc.reason {
+				t.Errorf("Expected %s (%s), got %s (%s) instead\n", tc.state, tc.reason, tc.ps.State, tc.ps.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package scan
+
+import (
+	"context"
+	"errors"
+)
+
+// synScanner is not implemented outside Linux, where raw sockets do not
+// deliver TCP replies the same way.
+type synScanner struct{}
+
+// newSYNScanner always fails outside Linux.
+func newSYNScanner() (*synScanner, error) {
+	return nil, errors.New("SYN scans are only supported on Linux")
+}
+
+func (s *synScanner) close() {}
+
+func (s *synScanner) scanPort(ctx context.Context, host, addr string, port int, cfg Config) (PortState, error) {
+	return scanPort(ctx, host, addr, port, cfg)
+}
@@ -0,0 +1,150 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidHost = errors.New("Invalid host")
+
+// ExpandHost calls fn for each concrete host described by entry, in order,
+// until fn returns false. Entries can be:
+//
+//   - a hostname or an IP address, used as is
+//   - a CIDR block, such as 10.0.0.0/24 or 2001:db8::/120
+//   - a range of addresses, such as 10.0.0.1-50 or 10.0.0.1-10.0.1.10
+//
+// Addresses are generated one at a time, so large blocks are never held
+// in memory.
+func ExpandHost(entry string, fn func(host string) bool) error {
+	first, last, isRange, err := parseEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	if !isRange {
+		fn(entry)
+		return nil
+	}
+
+	for addr := first; addr.IsValid() && addr.Compare(last) <= 0; addr = addr.Next() {
+		if !fn(addr.String()) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// CountHosts returns the number of hosts ExpandHost generates for entry, up
+// to math.MaxUint64 for the largest IPv6 blocks. Invalid entries count as a
+// single host, as the scans report them as not found.
+func CountHosts(entry string) uint64 {
+	first, last, isRange, err := parseEntry(entry)
+	if err != nil || !isRange {
+		return 1
+	}
+
+	f, l := first.As16(), last.As16()
+
+	n := new(big.Int).Sub(new(big.Int).SetBytes(l[:]), new(big.Int).SetBytes(f[:]))
+	n.Add(n, big.NewInt(1))
+
+	if !n.IsUint64() {
+		return math.MaxUint64
+	}
+
+	return n.Uint64()
+}
+
+// parseEntry returns the first and last addresses of a CIDR block or range
+// entry. isRange is false when entry is a single host.
+func parseEntry(entry string) (first, last netip.Addr, isRange bool, err error) {
+	// IPv6 zones, as in fe80::1%eth0, can contain dashes too.
+	if _, err := netip.ParseAddr(entry); err == nil {
+		return first, last, false, nil
+	}
+
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return first, last, false, fmt.Errorf("%w: %s: %v", ErrInvalidHost, entry, err)
+		}
+
+		prefix = prefix.Masked()
+
+		return prefix.Addr(), lastAddr(prefix), true, nil
+	}
+
+	start, end, ok := strings.Cut(entry, "-")
+	if !ok {
+		return first, last, false, nil
+	}
+
+	// Hostnames may contain dashes, so only entries starting with an IP
+	// address are ranges.
+	first, err = netip.ParseAddr(start)
+	if err != nil {
+		return first, last, false, nil
+	}
+
+	last, err = netip.ParseAddr(end)
+	if err != nil {
+		// Short form for the last octet of IPv4 addresses, as in 10.0.0.1-50.
+		octet, convErr := strconv.Atoi(end)
+		if convErr != nil || !first.Is4() || octet < 0 || octet > 255 {
+			return first, last, false, fmt.Errorf("%w: %s: invalid range end %q", ErrInvalidHost, entry, end)
+		}
+
+		a := first.As4()
+		a[3] = byte(octet)
+		last = netip.AddrFrom4(a)
+	}
+
+	if first.BitLen() != last.BitLen() || last.Less(first) {
+		return first, last, false, fmt.Errorf("%w: %s: invalid range", ErrInvalidHost, entry)
+	}
+
+	return first, last, true, nil
+}
+
+// lastAddr returns the last address in prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	a := prefix.Addr().AsSlice()
+
+	for bit := prefix.Bits(); bit < len(a)*8; bit++ {
+		a[bit/8] |= 0x80 >> (bit % 8)
+	}
+
+	addr, _ := netip.AddrFromSlice(a)
+	return addr
+}
+
+// selectAddrs returns the addresses of the IP version selected by
+// cfg.IPVersion, in their original order. Addresses that cannot be parsed
+// are only kept when both versions are.
+func selectAddrs(addrs []string, cfg Config) []string {
+	if cfg.IPVersion == 0 {
+		return addrs
+	}
+
+	var selected []string
+
+	for _, a := range addrs {
+		addr, err := netip.ParseAddr(a)
+		if err != nil {
+			continue
+		}
+
+		if addr.Unmap().Is4() == (cfg.IPVersion == 4) {
+			selected = append(selected, a)
+		}
+	}
+
+	return selected
+}
@@ -0,0 +1,109 @@
+package scan_test
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+func TestExpandHost(t *testing.T) {
+	testCases := []struct {
+		name      string
+		entry     string
+		expected  []string
+		expectErr error
+	}{
+		{"Hostname", "host1", []string{"host1"}, nil},
+		{"HostnameWithDash", "my-host-1", []string{"my-host-1"}, nil},
+		{"Address", "10.0.0.1", []string{"10.0.0.1"}, nil},
+		{"CIDR", "10.0.0.0/30", []string{"10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3"}, nil},
+		{"CIDRNotMasked", "10.0.0.9/31", []string{"10.0.0.8", "10.0.0.9"}, nil},
+		{"CIDRHost", "10.0.0.1/32", []string{"10.0.0.1"}, nil},
+		{"AddressWithZone", "fe80::1%br-lan", []string{"fe80::1%br-lan"}, nil},
+		{"CIDRv6", "2001:db8::/127", []string{"2001:db8::", "2001:db8::1"}, nil},
+		{"RangeShort", "10.0.0.254-255", []string{"10.0.0.254", "10.0.0.255"}, nil},
+		{"RangeLong", "10.0.0.255-10.0.1.1", []string{"10.0.0.255", "10.0.1.0", "10.0.1.1"}, nil},
+		{"RangeV6", "2001:db8::ff-2001:db8::100", []string{"2001:db8::ff", "2001:db8::100"}, nil},
+		{"CIDREndOfSpace", "255.255.255.254/31", []string{"255.255.255.254", "255.255.255.255"}, nil},
+		{"InvalidCIDR", "10.0.0.0/33", nil, scan.ErrInvalidHost},
+		{"InvalidRangeEnd", "10.0.0.1-256", nil, scan.ErrInvalidHost},
+		{"ReversedRange", "10.0.0.9-1", nil, scan.ErrInvalidHost},
+		{"MixedFamilies", "10.0.0.1-2001:db8::1", nil, scan.ErrInvalidHost},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var hosts []string
+
+			err := scan.ExpandHost(tc.entry, func(host string) bool {
+				hosts = append(hosts, host)
+				return true
+			})
+
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) {
+					t.Fatalf("Expected error %q, got %v instead\n", tc.expectErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+
+			if !reflect.DeepEqual(hosts, tc.expected) {
+				t.Errorf("Expected hosts %v, got %v instead\n", tc.expected, hosts)
+			}
+		})
+	}
+}
+
+// Test that expansion stops as soon as the callback returns false, so a
+// large block is never fully generated.
+func TestExpandHostStop(t *testing.T) {
+	count := 0
+
+	err := scan.ExpandHost("10.0.0.0/8", func(host string) bool {
+		count++
+		return count < 3
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+
+	if count != 3 {
+		t.Errorf("Expected 3 hosts, got %d instead\n", count)
+	}
+}
+
+func TestCountHosts(t *testing.T) {
+	testCases := []struct {
+		entry    string
+		expected uint64
+	}{
+		{"host1", 1},
+		{"10.0.0.1", 1},
+		{"10.0.0.0/24", 256},
+		{"10.0.0.0/8", 1 << 24},
+		{"0.0.0.0/0", 1 << 32},
+		{"10.0.0.1-50", 50},
+		{"10.0.0.255-10.0.1.1", 3},
+		{"2001:db8::/120", 256},
+		{"2001:db8::/32", math.MaxUint64},
+		{"10.0.0.9-1", 1},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.entry, func(t *testing.T) {
+			if n := scan.CountHosts(tc.entry); n != tc.expected {
+				t.Errorf("Expected %d hosts, got %d instead\n", tc.expected, n)
+			}
+		})
+	}
+}
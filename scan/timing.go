@@ -0,0 +1,90 @@
+package scan
+
+import (
+	"sync"
+	"time"
+)
+
+// MinAdaptiveTimeout is the shortest timeout set by the adaptive timing,
+// so a few fast answers do not make the slower ones look filtered.
+const MinAdaptiveTimeout = 100 * time.Millisecond
+
+// Timing describes the timeout the adaptive timing chose for a host, and
+// the round-trip times it was chosen from.
+type Timing struct {
+	// Timeout is the timeout of the host at the end of its scan.
+	Timeout time.Duration `json:"timeout_ns"`
+	// SRTT is the smoothed round-trip time of the host, and RTTVar its
+	// variation, as in TCP.
+	SRTT   time.Duration `json:"srtt_ns"`
+	RTTVar time.Duration `json:"rttvar_ns"`
+	// Samples is the number of answers the round-trip time was measured
+	// from.
+	Samples int `json:"samples"`
+}
+
+// rttEstimator tunes the timeout of a host from the round-trip times of
+// the ports that answered, open or closed, the way TCP computes its
+// retransmission timeout: the smoothed round-trip time plus four times its
+// variation, kept between MinAdaptiveTimeout and limit.
+type rttEstimator struct {
+	mu    sync.Mutex
+	limit time.Duration
+	t     Timing
+}
+
+// newRTTEstimator returns an estimator starting at, and never going above,
+// the timeout limit.
+func newRTTEstimator(limit time.Duration) *rttEstimator {
+	return &rttEstimator{limit: limit, t: Timing{Timeout: limit}}
+}
+
+// sample updates the timeout with the round-trip time of an answer.
+func (e *rttEstimator) sample(rtt time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.t.Samples == 0 {
+		e.t.SRTT, e.t.RTTVar = rtt, rtt/2
+	} else {
+		diff := e.t.SRTT - rtt
+		if diff < 0 {
+			diff = -diff
+		}
+
+		e.t.RTTVar = (3*e.t.RTTVar + diff) / 4
+		e.t.SRTT = (7*e.t.SRTT + rtt) / 8
+	}
+
+	e.t.Samples++
+	e.t.Timeout = min(max(e.t.SRTT+4*e.t.RTTVar, MinAdaptiveTimeout), e.limit)
+}
+
+// timeout returns the current timeout of the host.
+func (e *rttEstimator) timeout() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.t.Timeout
+}
+
+// timing returns a copy of the current state of the estimator.
+func (e *rttEstimator) timing() *Timing {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	t := e.t
+
+	return &t
+}
+
+// answered reports whether the port answered, so its latency measures the
+// round-trip time to the host.
+func answered(p PortState) bool {
+	switch p.Reason {
+	case ReasonSynAck, ReasonConnRefused, ReasonReset:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,315 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultTLSTimeout is how long the TLS handshake, including STARTTLS, can
+// take when the configuration does not set it.
+const DefaultTLSTimeout = 5 * time.Second
+
+// TLSInfo describes the TLS session and certificate found on an open port.
+type TLSInfo struct {
+	// StartTLS is the protocol used to upgrade the connection to TLS, such
+	// as "smtp", or empty when the port speaks TLS right away.
+	StartTLS string `json:"starttls,omitempty"`
+	// Version, Cipher and ALPN describe the negotiated session, such as
+	// "TLS 1.3", "TLS_AES_128_GCM_SHA256" and "h2".
+	Version string `json:"version"`
+	Cipher  string `json:"cipher"`
+	ALPN    string `json:"alpn,omitempty"`
+	// Subject, SANs and Issuer identify the leaf certificate.
+	Subject string   `json:"subject"`
+	SANs    []string `json:"sans,omitempty"`
+	Issuer  string   `json:"issuer"`
+	// NotBefore and NotAfter bound the validity of the leaf certificate.
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	// DaysToExpiry is the number of whole days left before NotAfter, and
+	// negative once the certificate expired.
+	DaysToExpiry int `json:"days_to_expiry"`
+	// SelfSigned is true when the leaf certificate signed itself.
+	SelfSigned bool `json:"self_signed"`
+	// HostnameMatch is true when the leaf certificate is valid for the
+	// scanned host.
+	HostnameMatch bool `json:"hostname_match"`
+	// Verified is true when the chain sent by the server leads to one of
+	// the trusted roots, Config.RootCAs or the system pool. Otherwise
+	// VerifyError tells why.
+	Verified    bool   `json:"verified"`
+	VerifyError string `json:"verify_error,omitempty"`
+	// LegacyVersions lists the deprecated versions, TLS 1.0 and TLS 1.1,
+	// the server still accepts, and WeakCiphers the insecure cipher suites.
+	// Only set when Config.CheckTLS is true.
+	LegacyVersions []string `json:"legacy_versions,omitempty"`
+	WeakCiphers    []string `json:"weak_ciphers,omitempty"`
+}
+
+// startTLSProtocols maps service names, as found by the probes or in the
+// services table, to the protocol used to upgrade their connections to TLS.
+var startTLSProtocols = map[string]string{
+	"smtp":       "smtp",
+	"submission": "smtp",
+	"imap2":      "imap",
+	"pop3":       "pop3",
+	"ftp":        "ftp",
+	"postgresql": "postgresql",
+}
+
+// startTLSProtocol returns the STARTTLS protocol for the port, based on the
+// identified service when there is one, or the port number otherwise.
+func startTLSProtocol(port int, svc *Service) string {
+	if svc != nil {
+		return startTLSProtocols[svc.Name]
+	}
+
+	return startTLSProtocols[ServiceName(port)]
+}
+
+// inspectTLS performs a TLS handshake with the open port at address, using
+// STARTTLS when the service needs it. It returns nil when the port does not
+// speak TLS.
+func inspectTLS(ctx context.Context, host, address string, port int, svc *Service, cfg Config) *TLSInfo {
+	starttls := startTLSProtocol(port, svc)
+
+	tcfg := &tls.Config{
+		InsecureSkipVerify: true, //nolint:gosec // The chain is verified below.
+		NextProtos:         []string{"h2", "http/1.1"},
+		MinVersion:         tls.VersionTLS10,
+	}
+
+	// Addresses, including IPv6 ones with a zone, are not server names.
+	if _, err := netip.ParseAddr(host); err != nil {
+		tcfg.ServerName = host
+	}
+
+	state, err := tlsHandshake(ctx, address, starttls, tcfg, cfg)
+	if err != nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	leaf := state.PeerCertificates[0]
+
+	info := &TLSInfo{
+		StartTLS:      starttls,
+		Version:       tls.VersionName(state.Version),
+		Cipher:        tls.CipherSuiteName(state.CipherSuite),
+		ALPN:          state.NegotiatedProtocol,
+		Subject:       leaf.Subject.String(),
+		Issuer:        leaf.Issuer.String(),
+		NotBefore:     leaf.NotBefore,
+		NotAfter:      leaf.NotAfter,
+		DaysToExpiry:  int(math.Floor(time.Until(leaf.NotAfter).Hours() / 24)),
+		SelfSigned:    bytes.Equal(leaf.RawIssuer, leaf.RawSubject) && leaf.CheckSignatureFrom(leaf) == nil,
+		HostnameMatch: leaf.VerifyHostname(host) == nil,
+	}
+
+	info.SANs = append(info.SANs, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+
+	// The hostname is checked on its own above, so a mismatch does not hide
+	// problems with the chain.
+	opts := x509.VerifyOptions{Roots: cfg.RootCAs, Intermediates: x509.NewCertPool()}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	if _, err := leaf.Verify(opts); err != nil {
+		info.VerifyError = err.Error()
+	} else {
+		info.Verified = true
+	}
+
+	if cfg.CheckTLS {
+		info.LegacyVersions = legacyVersions(ctx, address, starttls, tcfg, cfg)
+		info.WeakCiphers = weakCiphers(ctx, address, starttls, tcfg, cfg)
+	}
+
+	return info
+}
+
+// legacyVersions returns the deprecated TLS versions accepted by the server,
+// with one handshake for each.
+func legacyVersions(ctx context.Context, address, starttls string, tcfg *tls.Config, cfg Config) []string {
+	var versions []string
+
+	for _, v := range []uint16{tls.VersionTLS10, tls.VersionTLS11} {
+		vcfg := tcfg.Clone()
+		vcfg.MinVersion, vcfg.MaxVersion = v, v
+
+		if _, err := tlsHandshake(ctx, address, starttls, vcfg, cfg); err == nil {
+			versions = append(versions, tls.VersionName(v))
+		}
+	}
+
+	return versions
+}
+
+// weakCiphers returns the insecure cipher suites accepted by the server. It
+// offers all of them, and then all but the ones already accepted, until the
+// server refuses the handshake.
+func weakCiphers(ctx context.Context, address, starttls string, tcfg *tls.Config, cfg Config) []string {
+	var names []string
+
+	offered := map[uint16]string{}
+	for _, cs := range tls.InsecureCipherSuites() {
+		offered[cs.ID] = cs.Name
+	}
+
+	for len(offered) > 0 {
+		ccfg := tcfg.Clone()
+		ccfg.MaxVersion = tls.VersionTLS12
+
+		for id := range offered {
+			ccfg.CipherSuites = append(ccfg.CipherSuites, id)
+		}
+
+		state, err := tlsHandshake(ctx, address, starttls, ccfg, cfg)
+		if err != nil {
+			break
+		}
+
+		name, ok := offered[state.CipherSuite]
+		if !ok {
+			break
+		}
+
+		names = append(names, name)
+		delete(offered, state.CipherSuite)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// tlsHandshake connects to address, upgrades the connection with the
+// STARTTLS protocol if not empty, and performs a TLS handshake with tcfg,
+// bounded by the TLS timeout. It returns the state of the established
+// session.
+func tlsHandshake(ctx context.Context, address, starttls string, tcfg *tls.Config, cfg Config) (tls.ConnectionState, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.tlsTimeout())
+	defer cancel()
+
+	conn, err := cfg.dialer().DialContext(ctx, "tcp", address)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return tls.ConnectionState{}, err
+	}
+
+	if err := startTLS(conn, starttls); err != nil {
+		return tls.ConnectionState{}, err
+	}
+
+	tlsConn := tls.Client(conn, tcfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return tls.ConnectionState{}, err
+	}
+
+	return tlsConn.ConnectionState(), nil
+}
+
+// errStartTLS is returned when the server refuses to upgrade the connection
+// to TLS.
+var errStartTLS = errors.New("server refused STARTTLS")
+
+// startTLS asks the server to upgrade conn to TLS with the protocol. It
+// does nothing when protocol is empty.
+func startTLS(conn net.Conn, protocol string) error {
+	r := bufio.NewReader(conn)
+
+	switch protocol {
+	case "":
+		return nil
+	case "smtp":
+		return exchange(conn, r, "", "220", "EHLO pscan\r\n", "250", "STARTTLS\r\n", "220")
+	case "imap":
+		return exchange(conn, r, "", "* OK", "a001 STARTTLS\r\n", "a001 OK")
+	case "pop3":
+		return exchange(conn, r, "", "+OK", "STLS\r\n", "+OK")
+	case "ftp":
+		return exchange(conn, r, "", "220", "AUTH TLS\r\n", "234")
+	case "postgresql":
+		req := make([]byte, 8)
+		binary.BigEndian.PutUint32(req[0:4], 8)
+		binary.BigEndian.PutUint32(req[4:8], 80877103)
+
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		if b != 'S' {
+			return errStartTLS
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown STARTTLS protocol %q", protocol)
+}
+
+// exchange runs a line based dialog with the server. steps alternate the
+// command to send, empty to only read, and the prefix expected in the
+// reply. Replies that span several lines, such as "250-PIPELINING", are
+// read up to their last line.
+func exchange(conn net.Conn, r *bufio.Reader, steps ...string) error {
+	for i := 0; i+1 < len(steps); i += 2 {
+		if steps[i] != "" {
+			if _, err := io.WriteString(conn, steps[i]); err != nil {
+				return err
+			}
+		}
+
+		line, err := readReply(r)
+		if err != nil {
+			return err
+		}
+
+		if !strings.HasPrefix(line, steps[i+1]) {
+			return errStartTLS
+		}
+	}
+
+	return nil
+}
+
+// readReply reads a server reply and returns its last line. Lines with a
+// dash after the three digit code, as in SMTP and FTP, continue the reply.
+func readReply(r *bufio.Reader) (string, error) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+
+		if len(line) < 4 || line[3] != '-' {
+			return line, nil
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package scan_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dbaker1298/pScan/scan"
+)
+
+// tlsServer starts an HTTPS server on localhost with the test certificate
+// of the httptest package, valid for example.com, 127.0.0.1 and ::1 among
+// others.
+func tlsServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestRunTLS(t *testing.T) {
+	srv := tlsServer(t)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	testCases := []struct {
+		name          string
+		host          string
+		roots         *x509.CertPool
+		hostnameMatch bool
+		verified      bool
+	}{
+		{name: "Trusted", host: "127.0.0.1", roots: roots, hostnameMatch: true, verified: true},
+		{name: "Untrusted", host: "127.0.0.1", roots: x509.NewCertPool(), hostnameMatch: true, verified: false},
+		{name: "HostnameMismatch", host: "localhost", roots: roots, hostnameMatch: false, verified: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			hl := &scan.HostsList{}
+			hl.Add(tc.host)
+
+			res := scan.RunWithConfig(hl, []int{serverPort(t, srv)}, scan.Config{TLS: true, RootCAs: tc.roots})
+
+			info := res[0].PortStates[0].TLS
+			if info == nil {
+				t.Fatal("Expected TLS information, got nil instead")
+			}
+
+			if info.Version != "TLS 1.3" || info.Cipher == "" || info.ALPN != "http/1.1" || info.StartTLS != "" {
+				t.Errorf("Unexpected session: %+v\n", info)
+			}
+
+			if info.Subject != "O=Acme Co" || info.Issuer != "O=Acme Co" || !info.SelfSigned {
+				t.Errorf("Unexpected certificate: %+v\n", info)
+			}
+
+			expectedSANs := []string{"example.com", "*.example.com", "127.0.0.1", "::1"}
+			if !reflect.DeepEqual(info.SANs, expectedSANs) {
+				t.Errorf("Expected SANs %v, got %v instead\n", expectedSANs, info.SANs)
+			}
+
+			if info.DaysToExpiry < 365 || info.DaysToExpiry != int(time.Until(info.NotAfter).Hours()/24) {
+				t.Errorf("Unexpected days to expiry %d for %s\n", info.DaysToExpiry, info.NotAfter)
+			}
+
+			if info.HostnameMatch != tc.hostnameMatch {
+				t.Errorf("Expected hostname match %t, got %t instead\n", tc.hostnameMatch, info.HostnameMatch)
+			}
+
+			if info.Verified != tc.verified || (info.VerifyError == "") != tc.verified {
+				t.Errorf("Expected verified %t, got %t (%s) instead\n", tc.verified, info.Verified, info.VerifyError)
+			}
+		})
+	}
+}
+
+func TestRunStartTLS(t *testing.T) {
+	tcfg := tlsServer(t).TLS
+
+	port := probeServer(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+
+		io.WriteString(conn, "220 mail.example.com ESMTP Postfix\r\n")
+
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				io.WriteString(conn, "250-mail.example.com\r\n250-PIPELINING\r\n250 STARTTLS\r\n")
+			case strings.HasPrefix(line, "STARTTLS"):
+				io.WriteString(conn, "220 2.0.0 Ready to start TLS\r\n")
+
+				tlsConn := tls.Server(conn, tcfg)
+				tlsConn.Handshake()
+				tlsConn.Close()
+
+				return
+			default:
+				io.WriteString(conn, "502 5.5.2 Error: command not recognized\r\n")
+			}
+		}
+	})
+
+	hl := &scan.HostsList{}
+	hl.Add("127.0.0.1")
+
+	// The smtp probe identifies the service, which selects STARTTLS on a
+	// port not in the services table.
+	cfg := scan.Config{TLS: true, Probes: []scan.Probe{scan.LookupProbe("smtp")}}
+
+	res := scan.RunWithConfig(hl, []int{port}, cfg)
+
+	info := res[0].PortStates[0].TLS
+	if info == nil {
+		t.Fatal("Expected TLS information, got nil instead")
+	}
+
+	if info.StartTLS != "smtp" || info.Subject != "O=Acme Co" {
+		t.Errorf("Unexpected TLS information: %+v\n", info)
+	}
+}
+
+func TestRunNoTLS(t *testing.T) {
+	port := probeServer(t, func(conn net.Conn) {
+		io.WriteString(conn, "hello\r\n")
+		io.Copy(io.Discard, conn)
+	})
+
+	hl := &scan.HostsList{}
+	hl.Add("127.0.0.1")
+
+	res := scan.RunWithConfig(hl, []int{port}, scan.Config{TLS: true, TLSTimeout: 200 * time.Millisecond})
+
+	if info := res[0].PortStates[0].TLS; info != nil {
+		t.Errorf("Expected no TLS information, got %+v instead\n", info)
+	}
+}
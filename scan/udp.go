@@ -0,0 +1,132 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// DefaultUDPTimeout is how long to wait for an answer from a UDP port when
+// the configuration does not set it.
+const DefaultUDPTimeout = 1 * time.Second
+
+// Reasons reported for UDP ports in PortState.
+const (
+	ReasonUDPResponse = "udp-response"
+	ReasonPortUnreach = "port-unreach"
+)
+
+// udpPayloads holds the datagrams sent to well-known UDP ports, crafted so
+// their services answer. Other ports get an empty datagram.
+var udpPayloads = map[int][]byte{
+	// DNS: query for the CH TXT record version.bind, which servers answer
+	// even when they refuse it.
+	53: {
+		0x70, 0x53, // ID
+		0x01, 0x00, // Standard query, recursion desired
+		0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // 1 question
+		0x07, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x04, 'b', 'i', 'n', 'd', 0x00,
+		0x00, 0x10, // TXT
+		0x00, 0x03, // CH
+	},
+	// NTP: version 3 client request, with the other fields left empty.
+	123: append([]byte{0x1b}, make([]byte, 47)...),
+	// SNMP: version 2c get-request for sysDescr.0 with the public community.
+	161: {
+		0x30, 0x29,
+		0x02, 0x01, 0x01, // version 2c
+		0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+		0xa0, 0x1c, // get-request
+		0x02, 0x04, 0x70, 0x53, 0x63, 0x6e, // request-id
+		0x02, 0x01, 0x00, // error-status
+		0x02, 0x01, 0x00, // error-index
+		0x30, 0x0e, 0x30, 0x0c,
+		0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, // 1.3.6.1.2.1.1.1.0
+		0x05, 0x00, // NULL
+	},
+	// syslog: a notice from the user facility. Servers never answer, so an
+	// open port shows as open|filtered.
+	514: []byte("<13>pScan: UDP port scan"),
+	// WireGuard: handshake initiation without valid keys. Servers drop it
+	// silently, so open ports show as open|filtered, but closed ones are
+	// still told apart.
+	51820: append([]byte{0x01, 0x00, 0x00, 0x00}, make([]byte, 144)...),
+}
+
+// scanUDPPort sends the payload for the port and waits for an answer. The
+// port is open when it answers, closed when the host reports it as
+// unreachable, and open|filtered when nothing comes back. It returns
+// ctx.Err() when ctx is done before the port state is known.
+func scanUDPPort(ctx context.Context, addr string, port int, cfg Config) (PortState, error) {
+	p := PortState{Port: port, Protocol: ProtocolUDP, Address: addr}
+
+	address := net.JoinHostPort(addr, fmt.Sprintf("%d", port))
+
+	start := time.Now()
+
+	conn, err := cfg.dial(ctx, "udp", address, cfg.timeout())
+	if err != nil {
+		if ctx.Err() != nil {
+			return p, ctx.Err()
+		}
+
+		p.Latency = time.Since(start)
+		p.State, p.Reason = classify(err)
+		p.Err = err
+
+		return p, nil
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(cfg.udpTimeout())
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	if err := conn.SetDeadline(deadline); err != nil {
+		return p, err
+	}
+
+	// Unblock the read below when ctx is canceled.
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	buf := make([]byte, 1500)
+
+	_, err = conn.Write(udpPayloads[port])
+	if err == nil {
+		_, err = conn.Read(buf)
+	}
+
+	p.Latency = time.Since(start)
+
+	if err != nil && ctx.Err() != nil {
+		return p, ctx.Err()
+	}
+
+	p.State, p.Reason = classifyUDP(err)
+	p.Err = err
+
+	return p, nil
+}
+
+// classifyUDP determines the port state and reason from the error returned
+// when reading the answer from a UDP port. Connected UDP sockets report
+// ICMP port unreachable messages as ECONNREFUSED.
+func classifyUDP(err error) (State, string) {
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return StateOpen, ReasonUDPResponse
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return StateClosed, ReasonPortUnreach
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return StateOpenFiltered, ReasonNoResponse
+	}
+
+	return classify(err)
+}
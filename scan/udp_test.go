@@ -0,0 +1,74 @@
+package scan_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Dbaker1298/pScan/scan"
+	"github.com/Dbaker1298/pScan/scan/scantest"
+)
+
+func TestRunUDP(t *testing.T) {
+	n := scantest.NewNetwork()
+
+	h := n.AddHost("dns.test")
+	h.UDP(53, scan.StateOpen).WithBanner("pong")
+	h.UDP(123, scan.StateOpenFiltered)
+
+	hl := &scan.HostsList{}
+	hl.Add("dns.test")
+
+	// Ports that are not declared are closed.
+	cfg := scan.Config{UDPPorts: []int{53, 123, 161}, UDPTimeout: 200 * time.Millisecond, Dialer: n, Resolver: n}
+
+	res := scan.RunWithConfig(hl, nil, cfg)
+
+	expected := []struct {
+		port   int
+		state  scan.State
+		reason string
+	}{
+		{53, scan.StateOpen, scan.ReasonUDPResponse},
+		{123, scan.StateOpenFiltered, scan.ReasonNoResponse},
+		{161, scan.StateClosed, scan.ReasonPortUnreach},
+	}
+
+	if len(res[0].PortStates) != len(expected) {
+		t.Fatalf("Expected %d ports, got %d instead\n", len(expected), len(res[0].PortStates))
+	}
+
+	for i, e := range expected {
+		p := res[0].PortStates[i]
+
+		if p.Port != e.port || p.Protocol != scan.ProtocolUDP || p.State != e.state || p.Reason != e.reason {
+			t.Errorf("Expected port %d/udp %s (%s), got %d/%s %s (%s) instead\n",
+				e.port, e.state, e.reason, p.Port, p.Protocol, p.State, p.Reason)
+		}
+	}
+}
+
+func TestRunTCPAndUDP(t *testing.T) {
+	n := scantest.NewNetwork()
+
+	h := n.AddHost("dns.test")
+	h.TCP(53, scan.StateOpen)
+	h.UDP(53, scan.StateOpen).WithBanner("pong")
+
+	hl := &scan.HostsList{}
+	hl.Add("dns.test")
+
+	res := scan.RunWithConfig(hl, []int{53}, scan.Config{UDPPorts: []int{53}, Dialer: n, Resolver: n})
+
+	ports := res[0].PortStates
+	if len(ports) != 2 {
+		t.Fatalf("Expected 2 ports, got %d instead\n", len(ports))
+	}
+
+	if ports[0].Port != 53 || ports[0].Protocol != scan.ProtocolTCP || ports[0].State != scan.StateOpen {
+		t.Errorf("Expected TCP port 53 open, got %+v instead\n", ports[0])
+	}
+
+	if ports[1].Port != 53 || ports[1].Protocol != scan.ProtocolUDP || ports[1].State != scan.StateOpen {
+		t.Errorf("Expected UDP port 53 open, got %+v instead\n", ports[1])
+	}
+}